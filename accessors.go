@@ -0,0 +1,176 @@
+package goformkeeper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoValidValue is wrapped by the errors of the typed accessors such as
+// ValidInt when the field failed, isn't in the form, or is empty. Other
+// errors, which tell the value doesn't parse, don't include the value, as
+// the field may be sensitive.
+var ErrNoValidValue = errors.New("No valid value")
+
+// validValue returns the valid value of the field, or an error wrapping
+// ErrNoValidValue.
+func (result *Result) validValue(name string) (string, error) {
+	value, found := result.ValidFields[name]
+	if !found || value == "" {
+		return "", fmt.Errorf("%w for '%s'", ErrNoValidValue, name)
+	}
+	return value, nil
+}
+
+// validValues returns the valid values of the selection, or an error
+// wrapping ErrNoValidValue when the selection failed or isn't in the form.
+func (result *Result) validValues(name string) ([]string, error) {
+	values, found := result.ValidSelections[name]
+	if !found {
+		return nil, fmt.Errorf("%w for '%s'", ErrNoValidValue, name)
+	}
+	return values, nil
+}
+
+// ValidInt returns the valid value of the field as an int. It's parsed
+// in the same way as the 'int' constraint, so a field with the constraint
+// never gives a parse error.
+func (result *Result) ValidInt(name string) (int, error) {
+	n, err := result.ValidInt64(name)
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(n)) != n {
+		return 0, fmt.Errorf("Value of '%s' overflows int", name)
+	}
+	return int(n), nil
+}
+
+// ValidInt64 returns the valid value of the field as an int64.
+func (result *Result) ValidInt64(name string) (int64, error) {
+	value, err := result.validValue(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := parseInt(value)
+	if err != nil {
+		return 0, fmt.Errorf("Value of '%s' is not an int", name)
+	}
+	return n, nil
+}
+
+// ValidFloat returns the valid value of the field as a float64. It's
+// parsed in the same way as the 'number' constraint.
+func (result *Result) ValidFloat(name string) (float64, error) {
+	value, err := result.validValue(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := parseNumber(value)
+	if err != nil {
+		return 0, fmt.Errorf("Value of '%s' is not a number", name)
+	}
+	return n, nil
+}
+
+// ValidBool returns the valid value of the field as a bool. Besides the
+// values strconv.ParseBool accepts, 'on' and 'off', which checkboxes
+// send, are accepted. Case is ignored.
+func (result *Result) ValidBool(name string) (bool, error) {
+	value, err := result.validValue(name)
+	if err != nil {
+		return false, err
+	}
+	value = strings.ToLower(value)
+	switch value {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Value of '%s' is not a bool", name)
+	}
+	return b, nil
+}
+
+// ValidTime returns the valid value of the field as a time parsed with
+// layout, such as "2006-01-02" for <input type="date">.
+func (result *Result) ValidTime(name, layout string) (time.Time, error) {
+	value, err := result.validValue(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Value of '%s' is not a time", name)
+	}
+	return t, nil
+}
+
+// ValidDuration returns the valid value of the field as a duration such
+// as "1h30m", parsed with time.ParseDuration.
+func (result *Result) ValidDuration(name string) (time.Duration, error) {
+	value, err := result.validValue(name)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Value of '%s' is not a duration", name)
+	}
+	return d, nil
+}
+
+// ValidInts returns the valid values of the selection as ints.
+func (result *Result) ValidInts(name string) ([]int, error) {
+	ns, err := result.ValidInt64s(name)
+	if err != nil {
+		return nil, err
+	}
+	ints := make([]int, len(ns))
+	for i, n := range ns {
+		if int64(int(n)) != n {
+			return nil, fmt.Errorf("Value of '%s' overflows int", name)
+		}
+		ints[i] = int(n)
+	}
+	return ints, nil
+}
+
+// ValidInt64s returns the valid values of the selection as int64s.
+func (result *Result) ValidInt64s(name string) ([]int64, error) {
+	values, err := result.validValues(name)
+	if err != nil {
+		return nil, err
+	}
+	ns := make([]int64, len(values))
+	for i, value := range values {
+		n, err := parseInt(value)
+		if err != nil {
+			return nil, fmt.Errorf("Value of '%s' is not an int", name)
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
+
+// ValidFloats returns the valid values of the selection as float64s.
+func (result *Result) ValidFloats(name string) ([]float64, error) {
+	values, err := result.validValues(name)
+	if err != nil {
+		return nil, err
+	}
+	ns := make([]float64, len(values))
+	for i, value := range values {
+		n, err := parseNumber(value)
+		if err != nil {
+			return nil, fmt.Errorf("Value of '%s' is not a number", name)
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
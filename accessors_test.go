@@ -0,0 +1,73 @@
+package goformkeeper
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedAccessors(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/typed.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := httptest.NewRequest("GET", "/?page=3&price=-1.5&agree=on&since=2024-02-29&timeout=1m30s&limit=1000&ids=1&ids=20&weights=0.5&weights=2", nil)
+	result, err := rule.Validate("search", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+
+	if n, err := result.ValidInt("page"); err != nil || n != 3 {
+		t.Errorf("ValidInt: got %d, %v", n, err)
+	}
+	if n, err := result.ValidInt64("page"); err != nil || n != 3 {
+		t.Errorf("ValidInt64: got %d, %v", n, err)
+	}
+	if f, err := result.ValidFloat("price"); err != nil || f != -1.5 {
+		t.Errorf("ValidFloat: got %f, %v", f, err)
+	}
+	if b, err := result.ValidBool("agree"); err != nil || !b {
+		t.Errorf("ValidBool: got %v, %v", b, err)
+	}
+	if tm, err := result.ValidTime("since", "2006-01-02"); err != nil || !tm.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ValidTime: got %v, %v", tm, err)
+	}
+	if d, err := result.ValidDuration("timeout"); err != nil || d != 90*time.Second {
+		t.Errorf("ValidDuration: got %v, %v", d, err)
+	}
+	if ns, err := result.ValidInts("ids"); err != nil || !reflect.DeepEqual(ns, []int{1, 20}) {
+		t.Errorf("ValidInts: got %v, %v", ns, err)
+	}
+	if ns, err := result.ValidInt64s("ids"); err != nil || !reflect.DeepEqual(ns, []int64{1, 20}) {
+		t.Errorf("ValidInt64s: got %v, %v", ns, err)
+	}
+	if fs, err := result.ValidFloats("weights"); err != nil || !reflect.DeepEqual(fs, []float64{0.5, 2}) {
+		t.Errorf("ValidFloats: got %v, %v", fs, err)
+	}
+
+	// failed, unknown and empty values
+	for _, name := range []string{"limit", "unknown"} {
+		if _, err := result.ValidInt(name); !errors.Is(err, ErrNoValidValue) {
+			t.Errorf("ValidInt(%s): want ErrNoValidValue, got %v", name, err)
+		}
+	}
+	req = httptest.NewRequest("GET", "/?agree=maybe", nil)
+	result, _ = rule.Validate("search", req)
+	if _, err := result.ValidInt("page"); !errors.Is(err, ErrNoValidValue) {
+		t.Errorf("empty value: want ErrNoValidValue, got %v", err)
+	}
+	if _, err := result.ValidBool("agree"); err == nil || errors.Is(err, ErrNoValidValue) {
+		t.Errorf("ValidBool should fail to parse 'maybe': %v", err)
+	}
+	if _, err := result.ValidInts("unknown"); !errors.Is(err, ErrNoValidValue) {
+		t.Errorf("ValidInts(unknown): want ErrNoValidValue, got %v", err)
+	}
+}
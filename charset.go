@@ -0,0 +1,79 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// charsets by their labels. nil stands for UTF-8, which needs no decoding.
+var charsets = map[string]encoding.Encoding{
+	"utf-8":       nil,
+	"utf8":        nil,
+	"shift_jis":   japanese.ShiftJIS,
+	"shift-jis":   japanese.ShiftJIS,
+	"sjis":        japanese.ShiftJIS,
+	"x-sjis":      japanese.ShiftJIS,
+	"ms_kanji":    japanese.ShiftJIS,
+	"windows-31j": japanese.ShiftJIS,
+	"cp932":       japanese.ShiftJIS,
+	"euc-jp":      japanese.EUCJP,
+	"eucjp":       japanese.EUCJP,
+	"x-euc-jp":    japanese.EUCJP,
+}
+
+func lookupCharset(label string) (encoding.Encoding, bool) {
+	e, found := charsets[strings.ToLower(strings.TrimSpace(label))]
+	return e, found
+}
+
+func checkCharset(label string) error {
+	if label == "" {
+		return nil
+	}
+	if _, found := lookupCharset(label); !found {
+		return fmt.Errorf("Unknown charset '%s'", label)
+	}
+	return nil
+}
+
+// requestCharset returns the charset the values of the form are encoded
+// in: the one the request declares with the charset parameter of
+// Content-Type or the '_charset_' parameter, or the one of the form when
+// the request declares none it knows.
+func (form *Form) requestCharset(req *http.Request) encoding.Encoding {
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil {
+		if e, found := lookupCharset(params["charset"]); found {
+			return e
+		}
+	}
+	if e, found := lookupCharset(req.Form.Get("_charset_")); found {
+		return e
+	}
+	e, _ := lookupCharset(form.Charset)
+	return e
+}
+
+// decodeValues converts the values sent with the form from the charset
+// to UTF-8. It returns false when some of them have bytes which are not
+// in the charset. Headers, cookies and path parameters are kept as they
+// are.
+func decodeValues(charset encoding.Encoding, source string, values []string) ([]string, bool) {
+	if charset == nil || !isFormSource(source) {
+		return values, true
+	}
+	decoded := make([]string, len(values))
+	for i, value := range values {
+		s, err := charset.NewDecoder().String(value)
+		if err != nil || strings.ContainsRune(s, utf8.RuneError) {
+			return values, false
+		}
+		decoded[i] = s
+	}
+	return decoded, true
+}
@@ -0,0 +1,62 @@
+package goformkeeper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCharset(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/charset.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	post := func(body, contentType string) *http.Request {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+	const urlencoded = "application/x-www-form-urlencoded"
+
+	cases := []struct {
+		form        string
+		body        string
+		contentType string
+	}{
+		// the charset of the form
+		{"legacy", "name=%93%FA%96%7B%8C%EA", urlencoded},
+		// the charset of Content-Type
+		{"modern", "name=%C6%FC%CB%DC%B8%EC", urlencoded + "; charset=EUC-JP"},
+		{"legacy", "name=%E6%97%A5%E6%9C%AC%E8%AA%9E", urlencoded + "; charset=utf-8"},
+		// the charset of _charset_
+		{"modern", "name=%93%FA%96%7B%8C%EA&_charset_=Shift_JIS", urlencoded},
+	}
+	for _, c := range cases {
+		result, err := rule.Validate(c.form, post(c.body, c.contentType))
+		if err != nil {
+			t.Errorf("Failed to validate: %s", err.Error())
+			continue
+		}
+		if got := result.ValidParam("name"); got != "日本語" {
+			t.Errorf("%s %s: want 日本語, got %q", c.form, c.body, got)
+		}
+	}
+
+	result, _ := rule.Validate("legacy", post("name=%FF%FF", urlencoded))
+	if !result.FailedOnConstraint("name", "invalid_charset") || result.MessageOn("name") != "Input name" {
+		t.Errorf("undecodable value should fail on invalid_charset")
+	}
+	if values := result.Failures["name"].Values; len(values) != 0 {
+		t.Errorf("undecodable value shouldn't be kept in the failure, got %q", values)
+	}
+
+	if err := checkCharset("iso-2022-jp"); err == nil {
+		t.Errorf("unknown charset should be an error")
+	}
+}
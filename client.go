@@ -0,0 +1,238 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//go:embed js/goformkeeper.js
+var clientRuntime []byte
+
+// constraint types and filters implemented by js/goformkeeper.js
+var (
+	clientConstraints = map[string]bool{
+		"length": true, "rune_count": true, "display_width": true,
+		"alphabet": true, "alnum": true, "ascii": true, "ascii_without_space": true,
+		"hiragana": true, "katakana": true, "full_width": true, "half_width": true,
+		"regex": true, "url": true, "email": true, "loose_email": true,
+		"included": true, "int": true, "number": true,
+		"any_of": true, "all_of": true, "not": true, "when": true,
+	}
+	clientFilters = map[string]bool{
+		"trim": true, "lowercase": true, "uppercase": true, "trim_unicode": true,
+		"half_width_alnum": true, "full_width_alnum": true,
+		"half_width_ascii": true, "full_width_ascii": true,
+		"full_width_katakana": true, "hiragana": true, "katakana": true,
+		"nfc": true, "nfkc": true, "truncate": true, "replace": true,
+		"collapse_space": true, "normalize_newlines": true,
+		"strip_control": true, "strip_tags": true, "squeeze": true,
+	}
+)
+
+// ClientBundle is a form of a rule serialized for the JavaScript runtime
+// served by ClientRuntimeHandler. Marshal it with encoding/json, and pass
+// it to GoFormKeeper.Form on the page.
+type ClientBundle struct {
+	Form       string             `json:"form"`
+	Fields     []*ClientField     `json:"fields"`
+	Selections []*ClientSelection `json:"selections"`
+	// UnsupportedConstraints and UnsupportedFilters list the constraint
+	// types and filters of the form which the runtime doesn't implement,
+	// such as custom validators. The runtime skips them unless they're
+	// added with GoFormKeeper.addValidator or GoFormKeeper.addFilter.
+	UnsupportedConstraints []string `json:"unsupported_constraints"`
+	UnsupportedFilters     []string `json:"unsupported_filters"`
+}
+
+type ClientField struct {
+	Name        string              `json:"name"`
+	Required    bool                `json:"required"`
+	Default     string              `json:"default,omitempty"`
+	Message     string              `json:"message,omitempty"`
+	Filters     []*ClientFilter     `json:"filters"`
+	Constraints []*ClientConstraint `json:"constraints"`
+	FallThrough bool                `json:"fall_through"`
+	Duplicates  string              `json:"duplicates"`
+}
+
+type ClientSelection struct {
+	Name        string              `json:"name"`
+	Count       *ClientCount        `json:"count,omitempty"`
+	Message     string              `json:"message,omitempty"`
+	Filters     []*ClientFilter     `json:"filters"`
+	Constraints []*ClientConstraint `json:"constraints"`
+	FallThrough bool                `json:"fall_through"`
+}
+
+type ClientCount struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type ClientFilter struct {
+	Name    string                 `json:"name"`
+	Message string                 `json:"message,omitempty"`
+	Args    map[string]interface{} `json:"args,omitempty"`
+}
+
+type ClientConstraint struct {
+	Type        string                 `json:"type"`
+	ID          string                 `json:"id,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Criteria    map[string]interface{} `json:"criteria,omitempty"`
+	Constraints []*ClientConstraint    `json:"constraints,omitempty"`
+	If          []*ClientConstraint    `json:"if,omitempty"`
+}
+
+// ClientBundle exports the form for validation in the browser, so that
+// the page shows the same messages as the server before the form is sent.
+// Validation on the client is a convenience; validate the request on the
+// server all the same. Fields and selections whose source is 'header',
+// 'cookie' or 'path' aren't inputs of the form, and are left out.
+func (rule *Rule) ClientBundle(formName string) (*ClientBundle, error) {
+	form, found := rule.Forms[formName]
+	if !found {
+		return nil, fmt.Errorf("Form rule not found '%s'", formName)
+	}
+
+	bundle := &ClientBundle{
+		Form:       formName,
+		Fields:     make([]*ClientField, 0, len(form.Fields)),
+		Selections: make([]*ClientSelection, 0, len(form.Selections)),
+	}
+	constraints := NewUniqueStringArrayBuilder(0)
+	filters := NewUniqueStringArrayBuilder(0)
+
+	for _, field := range form.Fields {
+		if field.Name == "" {
+			return nil, fmt.Errorf("Field name not found on a rule for '%s'", formName)
+		}
+		if !isFormSource(field.Source) {
+			continue
+		}
+		bundle.Fields = append(bundle.Fields, &ClientField{
+			Name:        field.Name,
+			Required:    field.Required,
+			Default:     field.Default,
+			Message:     field.Message,
+			Filters:     newClientFilters(field.Filters, filters),
+			Constraints: newClientConstraints(field.Constraints, constraints),
+			FallThrough: field.FallThrough,
+			Duplicates:  field.duplicatesPolicy(form),
+		})
+	}
+
+	for _, selection := range form.Selections {
+		if selection.Name == "" {
+			return nil, fmt.Errorf("Selection name not found on a rule for '%s'", formName)
+		}
+		if !isFormSource(selection.Source) {
+			continue
+		}
+		s := &ClientSelection{
+			Name:        selection.Name,
+			Message:     selection.Message,
+			Filters:     newClientFilters(selection.Filters, filters),
+			Constraints: newClientConstraints(selection.Constraints, constraints),
+			FallThrough: selection.FallThrough,
+		}
+		if selection.Count != nil {
+			s.Count = &ClientCount{From: selection.Count.From, To: selection.Count.To}
+		}
+		bundle.Selections = append(bundle.Selections, s)
+	}
+
+	bundle.UnsupportedConstraints = constraints.Build()
+	bundle.UnsupportedFilters = filters.Build()
+	return bundle, nil
+}
+
+// newClientFilters converts the filters, adding the names the runtime
+// doesn't implement to unsupported.
+func newClientFilters(specs []*FilterSpec, unsupported *UniqueStringArrayBuilder) []*ClientFilter {
+	filters := make([]*ClientFilter, len(specs))
+	for i, spec := range specs {
+		if !clientFilters[spec.Name] {
+			unsupported.Add(spec.Name)
+		}
+		filters[i] = &ClientFilter{
+			Name:    spec.Name,
+			Message: spec.Message,
+			Args:    clientValue(spec.Args).(map[string]interface{}),
+		}
+	}
+	return filters
+}
+
+// newClientConstraints converts the constraints, adding the types the
+// runtime doesn't implement to unsupported.
+func newClientConstraints(constraints []*Constraint, unsupported *UniqueStringArrayBuilder) []*ClientConstraint {
+	if len(constraints) == 0 {
+		return []*ClientConstraint{}
+	}
+	results := make([]*ClientConstraint, len(constraints))
+	for i, constraint := range constraints {
+		if !clientConstraints[constraint.Type] {
+			unsupported.Add(constraint.Type)
+		}
+		c := &ClientConstraint{
+			Type:     constraint.Type,
+			ID:       constraint.ID,
+			Message:  constraint.Message,
+			Criteria: clientValue(constraint.Criteria).(map[string]interface{}),
+		}
+		if len(constraint.Constraints) > 0 {
+			c.Constraints = newClientConstraints(constraint.Constraints, unsupported)
+		}
+		if len(constraint.If) > 0 {
+			c.If = newClientConstraints(constraint.If, unsupported)
+		}
+		results[i] = c
+	}
+	return results
+}
+
+// clientValue converts a value parsed from YAML so that encoding/json can
+// marshal it. Mappings in YAML are parsed as map[interface{}]interface{}.
+func clientValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = clientValue(item)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = clientValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = clientValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// ClientRuntimeHandler serves the JavaScript runtime which validates forms
+// in the browser with the bundles made by Rule.ClientBundle.
+//
+//	http.Handle("/js/goformkeeper.js", goformkeeper.ClientRuntimeHandler())
+//
+// It answers conditional requests with the ETag of the script.
+func ClientRuntimeHandler() http.Handler {
+	etag := fmt.Sprintf("\"%x\"", sha256.Sum256(clientRuntime))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, req, "goformkeeper.js", time.Time{}, bytes.NewReader(clientRuntime))
+	})
+}
@@ -0,0 +1,299 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+	"golang.org/x/text/width"
+)
+
+func TestClientBundle(t *testing.T) {
+	path := "./tests/client.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	if _, err := rule.ClientBundle("unknown"); err == nil {
+		t.Errorf("ClientBundle for unknown form should fail")
+	}
+
+	bundle, err := rule.ClientBundle("profile")
+	if err != nil {
+		t.Errorf("Failed to export bundle %s", err.Error())
+		return
+	}
+	if len(bundle.Fields) != 3 || len(bundle.Selections) != 1 {
+		t.Errorf("bundle should have 3 fields and 1 selection, got %d and %d", len(bundle.Fields), len(bundle.Selections))
+		return
+	}
+
+	nickname := bundle.Fields[0]
+	if nickname.Name != "nickname" || !nickname.Required || nickname.Message != "Input your nickname" {
+		t.Errorf("referenced field should be merged, got %#v", nickname)
+	}
+	if len(nickname.Constraints) != 1 || nickname.Constraints[0].Message != "Nickname is too wide" {
+		t.Errorf("constraints of the referenced field not found")
+	}
+
+	hobby := bundle.Selections[0]
+	if hobby.Count == nil || hobby.Count.From != 1 || hobby.Count.To != 3 {
+		t.Errorf("count of selection not exported, got %#v", hobby.Count)
+	}
+
+	if want := []string{"expr", "jisx0208", "palindrome"}; !reflect.DeepEqual(bundle.UnsupportedConstraints, want) {
+		t.Errorf("UnsupportedConstraints: want %v, got %v", want, bundle.UnsupportedConstraints)
+	}
+	if want := []string{"shout"}; !reflect.DeepEqual(bundle.UnsupportedFilters, want) {
+		t.Errorf("UnsupportedFilters: want %v, got %v", want, bundle.UnsupportedFilters)
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Errorf("Failed to marshal bundle %s", err.Error())
+		return
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	code := decoded["fields"].([]interface{})[2].(map[string]interface{})
+	criteria := code["constraints"].([]interface{})[0].(map[string]interface{})["criteria"]
+	want := map[string]interface{}{"options": map[string]interface{}{"ignore_case": true}}
+	if !reflect.DeepEqual(criteria, want) {
+		t.Errorf("nested criteria: want %v, got %v", want, criteria)
+	}
+}
+
+func TestClientRuntimeHandler(t *testing.T) {
+	handler := ClientRuntimeHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/goformkeeper.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status: want 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+		t.Errorf("Content-Type: want text/javascript, got %s", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "GoFormKeeper") {
+		t.Errorf("runtime not served")
+	}
+
+	req := httptest.NewRequest("GET", "/goformkeeper.js", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status for the same ETag: want 304, got %d", rec.Code)
+	}
+}
+
+// The names the runtime defines should agree with the ones ClientBundle
+// treats as supported.
+func TestClientRuntimeNames(t *testing.T) {
+	script := string(clientRuntime)
+	section := func(start, end string) map[string]bool {
+		body := script[strings.Index(script, start):]
+		body = body[:strings.Index(body, end)]
+		names := make(map[string]bool)
+		for _, m := range regexp.MustCompile(`(?m)^    "?([a-z_0-9]+)"?: `).FindAllStringSubmatch(body, -1) {
+			names[m[1]] = true
+		}
+		return names
+	}
+
+	validators := section("var validators = {", "\n  };")
+	for _, name := range []string{"any_of", "all_of", "not", "when"} {
+		validators[name] = true
+	}
+	if !reflect.DeepEqual(validators, clientConstraints) {
+		t.Errorf("validators of the runtime: want %v, got %v", clientConstraints, validators)
+	}
+	if filters := section("var filters = {", "\n  };"); !reflect.DeepEqual(filters, clientFilters) {
+		t.Errorf("filters of the runtime: want %v, got %v", clientFilters, filters)
+	}
+}
+
+// The width tables of the runtime should agree with golang.org/x/text/width.
+func TestClientRuntimeWidthTables(t *testing.T) {
+	script := string(clientRuntime)
+	table := func(name string) [][2]rune {
+		body := script[strings.Index(script, "var "+name+" = ["):]
+		body = body[:strings.Index(body, "];")]
+		ranges := make([][2]rune, 0)
+		for _, m := range regexp.MustCompile(`0x([0-9A-F]+),0x([0-9A-F]+)`).FindAllStringSubmatch(body, -1) {
+			from, _ := strconv.ParseInt(m[1], 16, 32)
+			to, _ := strconv.ParseInt(m[2], 16, 32)
+			ranges = append(ranges, [2]rune{rune(from), rune(to)})
+		}
+		return ranges
+	}
+	inRanges := func(ranges [][2]rune, r rune) bool {
+		for _, rg := range ranges {
+			if r >= rg[0] && r <= rg[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	wide := table("WIDE")
+	ambiguous := table("AMBIGUOUS")
+	for r := rune(0); r <= 0x10FFFF; r++ {
+		kind := width.LookupRune(r).Kind()
+		isWide := kind == width.EastAsianWide || kind == width.EastAsianFullwidth
+		if inRanges(wide, r) != isWide {
+			t.Errorf("WIDE disagrees on U+%04X", r)
+			return
+		}
+		if inRanges(ambiguous, r) != (kind == width.EastAsianAmbiguous) {
+			t.Errorf("AMBIGUOUS disagrees on U+%04X", r)
+			return
+		}
+	}
+}
+
+// parityOutcome is what TestClientRuntimeParity compares between the
+// server and the client runtime: the constraint types each failure has,
+// and the valid values.
+type parityOutcome struct {
+	Failures        map[string][]string `json:"failures"`
+	ValidFields     map[string]string   `json:"valid_fields"`
+	ValidSelections map[string][]string `json:"valid_selections"`
+}
+
+const parityScript = `
+var GoFormKeeper = require(process.argv[1]);
+var form = new GoFormKeeper.Form(process.argv[2]);
+var outcomes = JSON.parse(require("fs").readFileSync(0, "utf8")).map(function (query) {
+  var result = form.validate(new URLSearchParams(query));
+  var failures = {};
+  Object.keys(result.failures).forEach(function (name) {
+    failures[name] = Object.keys(result.failures[name].constraints).sort();
+  });
+  return { failures: failures, valid_fields: result.validFields, valid_selections: result.validSelections };
+});
+process.stdout.write(JSON.stringify(outcomes));
+`
+
+func TestClientRuntimeParity(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	path := "./tests/parity.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+	bundle, err := rule.ClientBundle("signup")
+	if err != nil {
+		t.Errorf("Failed to export bundle %s", err.Error())
+		return
+	}
+	if len(bundle.UnsupportedConstraints) > 0 || len(bundle.UnsupportedFilters) > 0 {
+		t.Errorf("bundle should be supported by the runtime: %v %v", bundle.UnsupportedConstraints, bundle.UnsupportedFilters)
+		return
+	}
+
+	queries := []string{
+		"nickname=lyo&hobby=music",
+		"nickname=%E3%80%80lyo++kato%E3%80%80&kana=%E3%82%AB%E3%83%88%E3%82%A6&age=%EF%BC%92%EF%BC%90&hobby=+Music+&hobby=sport",
+		"nickname=&hobby=music&hobby=movie&hobby=sport",
+		"nickname=%E3%81%82%E3%81%82%E3%81%82%E3%81%82%E3%81%82%E3%81%82%E3%81%82&hobby=golf",
+		"nickname=a&nickname=b&hobby=music&kana=%E3%81%8B%E3%81%AA",
+		"nickname=lyo&hobby=music&address=%E6%9D%B1%E4%BA%AC%E9%83%BD%E2%80%BB%3Cb%3E%EF%BC%91%E4%B8%81%E7%9B%AE%3C%2Fb%3E",
+		"nickname=lyo&hobby=music&address=Tokyo%3Cb%3E1%3C%2Fb%3E",
+		"nickname=lyo&hobby=music&address=%3C%3Ca%3Eimg+src%3Dx+onerror%3Dalert(1)%3E",
+		"nickname=lyo&hobby=music&address=" + strings.Repeat("%3C", 10000) + "a%3E" + strings.Repeat("%EF%BC%A2%3E", 10000),
+		"nickname=lyo&hobby=music&age=17",
+		"nickname=lyo&hobby=music&age=abc",
+		"nickname=lyo&hobby=music&code=ab-c",
+		"nickname=lyo&hobby=music&code=abc123",
+		"nickname=lyo&hobby=music&code=abc123&code=def456",
+		"nickname=lyo&hobby=music&country=JP&zip=1234567",
+		"nickname=lyo&hobby=music&country=JP&zip=123-4567",
+		"nickname=lyo&hobby=music&country=US&zip=1234567",
+		"nickname=lyo&hobby=music&country=JP&country=US&zip=1234567",
+		"nickname=lyo&hobby=music&contact=%2B819012345678",
+		"nickname=lyo&hobby=music&contact=foo+bar",
+		"nickname=lyo&hobby=music&contact=admin",
+	}
+
+	want := make([]*parityOutcome, len(queries))
+	for i, query := range queries {
+		req := httptest.NewRequest("GET", "/?"+query, nil)
+		result, err := rule.Validate("signup", req)
+		if err != nil {
+			t.Errorf("Failed to validate: %s", err.Error())
+			return
+		}
+		outcome := &parityOutcome{
+			Failures:        make(map[string][]string),
+			ValidFields:     result.ValidFields,
+			ValidSelections: make(map[string][]string),
+		}
+		for name, failure := range result.Failures {
+			types := make([]string, 0, len(failure.Constraints))
+			for constraintType := range failure.Constraints {
+				types = append(types, constraintType)
+			}
+			sort.Strings(types)
+			outcome.Failures[name] = types
+		}
+		for name, values := range result.ValidSelections {
+			outcome.ValidSelections[name] = append([]string{}, values...)
+		}
+		want[i] = outcome
+	}
+
+	runtime := filepath.Join(t.TempDir(), "goformkeeper.js")
+	if err := os.WriteFile(runtime, clientRuntime, 0644); err != nil {
+		t.Errorf("Failed to write runtime %s", err.Error())
+		return
+	}
+	bundleJSON, _ := json.Marshal(bundle)
+	queriesJSON, _ := json.Marshal(queries)
+	cmd := exec.Command(node, "-e", parityScript, runtime, string(bundleJSON))
+	cmd.Stdin = bytes.NewReader(queriesJSON)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Errorf("Failed to run node %s", err.Error())
+		return
+	}
+	var got []*parityOutcome
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Errorf("Failed to parse output of node %s", err.Error())
+		return
+	}
+	if len(got) != len(queries) {
+		t.Errorf("node should return %d outcomes, got %d", len(queries), len(got))
+		return
+	}
+
+	for i, query := range queries {
+		if !reflect.DeepEqual(want[i], got[i]) {
+			t.Errorf("%s: server %v, client %v", query, pretty.Formatter(want[i]), pretty.Formatter(got[i]))
+		}
+	}
+}
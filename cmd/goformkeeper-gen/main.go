@@ -0,0 +1,281 @@
+// Command goformkeeper-gen generates typed accessors for the forms of a
+// rule, so that renaming a field in the rule files breaks the build instead
+// of silently returning "" from ValidParam.
+//
+// For each form, say 'signup' with a field 'email' and a selection 'hobby',
+// it generates
+//
+//	const (
+//		SignupFieldEmail = "email"
+//		SignupFieldHobby = "hobby"
+//	)
+//
+//	type SignupForm struct { ... }
+//
+//	func ValidateSignup(rule *goformkeeper.Rule, req *http.Request) (*SignupForm, error)
+//	func (f *SignupForm) Result() *goformkeeper.Result
+//	func (f *SignupForm) Email() string
+//	func (f *SignupForm) Hobby() []string
+//
+// Use it from go generate:
+//
+//	//go:generate goformkeeper-gen -rules ../conf/rule -o forms_gen.go
+//
+// The package name defaults to $GOPACKAGE, which go generate sets.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	fk "github.com/lyokato/goformkeeper"
+)
+
+func main() {
+	rulesPath := flag.String("rules", "", "rule file or directory")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	output := flag.String("o", "forms_gen.go", "file to write")
+	flag.Parse()
+
+	if *rulesPath == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(*rulesPath, *pkg, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(rulesPath, pkg, output string) error {
+	rule, err := loadRule(rulesPath)
+	if err != nil {
+		return err
+	}
+	src, err := generate(rule, pkg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0644)
+}
+
+func loadRule(path string) (*fk.Rule, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return fk.LoadRuleFromDir(path)
+	}
+	return fk.LoadRuleFromFile(path)
+}
+
+type formData struct {
+	Name   string
+	Type   string
+	Fields []*fieldData
+}
+
+type fieldData struct {
+	Name      string
+	Method    string
+	Selection bool
+}
+
+// methods which the generated struct has besides the accessors
+var reservedMethods = map[string]bool{
+	"Result": true,
+}
+
+func generate(rule *fk.Rule, pkg string) ([]byte, error) {
+	names := make([]string, 0, len(rule.Forms))
+	for name := range rule.Forms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	forms := make([]*formData, 0, len(names))
+	types := make(map[string]string)
+	for _, name := range names {
+		form, err := newFormData(rule, name)
+		if err != nil {
+			return nil, err
+		}
+		if other, found := types[form.Type]; found {
+			return nil, fmt.Errorf("Forms '%s' and '%s' have the same name in Go '%s'", other, name, form.Type)
+		}
+		types[form.Type] = name
+		forms = append(forms, form)
+	}
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Forms":   forms,
+	})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to format generated code: %s", err.Error())
+	}
+	return src, nil
+}
+
+func newFormData(rule *fk.Rule, formName string) (*formData, error) {
+	form := rule.Forms[formName]
+	data := &formData{
+		Name: formName,
+		Type: goName(formName),
+	}
+	if data.Type == "" {
+		return nil, fmt.Errorf("Form name '%s' can't be used in Go", formName)
+	}
+
+	methods := make(map[string]string)
+	add := func(name string, selection bool) error {
+		if name == "" {
+			return fmt.Errorf("Field name not found on a rule for '%s'", formName)
+		}
+		method := goName(name)
+		if method == "" {
+			return fmt.Errorf("Field name '%s' of form '%s' can't be used in Go", name, formName)
+		}
+		if reservedMethods[method] {
+			return fmt.Errorf("Field name '%s' of form '%s' conflicts with method %s", name, formName, method)
+		}
+		if other, found := methods[method]; found {
+			return fmt.Errorf("Fields '%s' and '%s' of form '%s' have the same name in Go '%s'", other, name, formName, method)
+		}
+		methods[method] = name
+		data.Fields = append(data.Fields, &fieldData{Name: name, Method: method, Selection: selection})
+		return nil
+	}
+
+	for _, field := range form.Fields {
+		name := field.Name
+		if name == "" && field.Ref != "" {
+			if ref, found := rule.Fields[field.Ref]; found {
+				name = ref.Name
+			}
+		}
+		if err := add(name, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, selection := range form.Selections {
+		name := selection.Name
+		if name == "" && selection.Ref != "" {
+			if ref, found := rule.Selections[selection.Ref]; found {
+				name = ref.Name
+			}
+		}
+		if err := add(name, true); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+var initialisms = map[string]string{
+	"api":  "API",
+	"html": "HTML",
+	"http": "HTTP",
+	"id":   "ID",
+	"ip":   "IP",
+	"json": "JSON",
+	"sql":  "SQL",
+	"ui":   "UI",
+	"uri":  "URI",
+	"url":  "URL",
+	"uuid": "UUID",
+	"xml":  "XML",
+}
+
+// goName converts a name in the rule such as 'user_id' or 'zip-code' into
+// an exported Go identifier such as 'UserID' or 'ZipCode'. It returns ""
+// when the name has no letter or digit.
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if initialism, found := initialisms[strings.ToLower(word)]; found {
+			b.WriteString(initialism)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	s := b.String()
+	if s == "" {
+		return ""
+	}
+	if first := []rune(s)[0]; !unicode.IsLetter(first) || !unicode.IsUpper(first) {
+		s = "X" + s
+	}
+	return s
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by goformkeeper-gen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"net/http"
+
+	"github.com/lyokato/goformkeeper"
+)
+{{ range .Forms }}{{ $form := . }}
+// Names of the fields and selections of the form '{{ .Name }}'.
+const (
+{{- range .Fields }}
+	{{ $form.Type }}Field{{ .Method }} = {{ printf "%q" .Name }}
+{{- end }}
+)
+
+// {{ .Type }}Form gives typed access to the result of validating the
+// form '{{ .Name }}'. The accessors return the valid values, or the zero
+// values when the field failed.
+type {{ .Type }}Form struct {
+	result *goformkeeper.Result
+}
+
+// Validate{{ .Type }} validates the request with the form '{{ .Name }}'.
+func Validate{{ .Type }}(rule *goformkeeper.Rule, req *http.Request) (*{{ .Type }}Form, error) {
+	result, err := rule.Validate({{ printf "%q" .Name }}, req)
+	if err != nil {
+		return nil, err
+	}
+	return &{{ .Type }}Form{result: result}, nil
+}
+
+// Result returns the result of the validation, for failures and messages.
+func (f *{{ .Type }}Form) Result() *goformkeeper.Result {
+	return f.result
+}
+{{ range .Fields }}
+{{- if .Selection }}
+func (f *{{ $form.Type }}Form) {{ .Method }}() []string {
+	return f.result.ValidSelection({{ $form.Type }}Field{{ .Method }})
+}
+{{ else }}
+func (f *{{ $form.Type }}Form) {{ .Method }}() string {
+	return f.result.ValidParam({{ $form.Type }}Field{{ .Method }})
+}
+{{ end }}
+{{- end }}
+{{- end }}`))
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fk "github.com/lyokato/goformkeeper"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goformkeeper-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "forms_gen.go")
+	err = run("testdata/rules.yml", "forms", output)
+	if err != nil {
+		t.Errorf("Failed to generate: %s", err.Error())
+		return
+	}
+
+	got, _ := ioutil.ReadFile(output)
+	want, _ := ioutil.ReadFile("testdata/forms_gen.golden")
+	if string(got) != string(want) {
+		t.Errorf("generated code differs from testdata/forms_gen.golden:\n%s", got)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"email":     "Email",
+		"user_id":   "UserID",
+		"zip-code":  "ZipCode",
+		"homeURL":   "HomeURL",
+		"items[]":   "Items",
+		"2nd_email": "X2ndEmail",
+		"__":        "",
+	}
+	for name, want := range cases {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q): want %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestGenerateConflict(t *testing.T) {
+	rule := &fk.Rule{
+		Forms: map[string]*fk.Form{
+			"signup": &fk.Form{
+				Fields: []*fk.Field{
+					&fk.Field{Name: "user_name"},
+					&fk.Field{Name: "user-name"},
+				},
+			},
+		},
+	}
+	if _, err := generate(rule, "forms"); err == nil {
+		t.Errorf("fields of the same name in Go should be an error")
+	}
+
+	rule.Forms["signup"].Fields = []*fk.Field{&fk.Field{Name: "result"}}
+	if _, err := generate(rule, "forms"); err == nil {
+		t.Errorf("field conflicting with Result should be an error")
+	}
+}
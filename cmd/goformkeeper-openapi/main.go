@@ -0,0 +1,349 @@
+// Command goformkeeper-openapi updates an OpenAPI 3 spec file in place with
+// the request bodies and query parameters generated from form rules.
+//
+// Mark each operation to generate with the form it accepts:
+//
+//	paths:
+//	  /signup:
+//	    post:
+//	      x-goformkeeper-form: signup
+//	      x-goformkeeper-media-type: multipart/form-data
+//
+// For GET, HEAD and DELETE operations the parameters are generated. For
+// the other methods the requestBody is replaced, and the parameters are
+// generated for the fields whose source is other than the body, such as
+// 'header' or 'path'. Parameters of the same names and locations are
+// replaced, and others are kept. The media type is taken from
+// x-goformkeeper-media-type, or from the existing requestBody when it's
+// a form, and defaults to application/x-www-form-urlencoded.
+//
+// Usage:
+//
+//	goformkeeper-openapi -rules conf/rule -spec openapi.yml
+//
+// Both YAML and JSON spec files are supported. The order of keys, and
+// comments in YAML, are kept as they are.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	fk "github.com/lyokato/goformkeeper"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	formKey      = "x-goformkeeper-form"
+	mediaTypeKey = "x-goformkeeper-media-type"
+)
+
+var queryMethods = map[string]bool{
+	"get":    true,
+	"head":   true,
+	"delete": true,
+}
+
+var bodyMethods = map[string]bool{
+	"put":     true,
+	"post":    true,
+	"patch":   true,
+	"options": true,
+	"trace":   true,
+}
+
+func main() {
+	rulesPath := flag.String("rules", "", "rule file or directory")
+	specPath := flag.String("spec", "", "OpenAPI spec file to update")
+	output := flag.String("o", "", "write the result to this file instead of updating the spec in place")
+	flag.Parse()
+
+	if *rulesPath == "" || *specPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(*rulesPath, *specPath, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(rulesPath, specPath, output string) error {
+	rule, err := loadRule(rulesPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return fmt.Errorf("Failed to parse spec %s: %s", specPath, err.Error())
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return fmt.Errorf("Spec %s is empty", specPath)
+	}
+
+	err = updateSpec(rule, doc.Content[0])
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	if strings.HasSuffix(specPath, ".json") {
+		var buf bytes.Buffer
+		err = writeJSON(&buf, doc.Content[0], "")
+		buf.WriteString("\n")
+		out = buf.Bytes()
+	} else {
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		err = encoder.Encode(&doc)
+		out = buf.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = specPath
+	}
+	return ioutil.WriteFile(output, out, 0644)
+}
+
+func loadRule(path string) (*fk.Rule, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return fk.LoadRuleFromDir(path)
+	}
+	return fk.LoadRuleFromFile(path)
+}
+
+func updateSpec(rule *fk.Rule, root *yaml.Node) error {
+	paths := mappingValue(root, "paths")
+	if paths == nil {
+		return errors.New("Spec has no paths")
+	}
+	for i := 0; i+1 < len(paths.Content); i += 2 {
+		path := paths.Content[i].Value
+		item := paths.Content[i+1]
+		for j := 0; j+1 < len(item.Content); j += 2 {
+			method := item.Content[j].Value
+			operation := item.Content[j+1]
+			formNode := mappingValue(operation, formKey)
+			if formNode == nil {
+				continue
+			}
+			var err error
+			if queryMethods[method] {
+				err = updateParameters(rule.OpenAPIParameters, operation, formNode.Value)
+			} else if bodyMethods[method] {
+				err = updateRequestBody(rule, operation, formNode.Value)
+				if err == nil {
+					err = updateParameters(rule.OpenAPIBodyParameters, operation, formNode.Value)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("Failed to update %s %s: %s", strings.ToUpper(method), path, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func updateRequestBody(rule *fk.Rule, operation *yaml.Node, formName string) error {
+	mediaType := fk.MediaTypeFormURLEncoded
+	if node := mappingValue(operation, mediaTypeKey); node != nil {
+		mediaType = node.Value
+	} else if body := mappingValue(operation, "requestBody"); body != nil {
+		if content := mappingValue(body, "content"); content != nil {
+			for i := 0; i < len(content.Content); i += 2 {
+				mt := content.Content[i].Value
+				if mt == fk.MediaTypeFormURLEncoded || mt == fk.MediaTypeMultipartFormData {
+					mediaType = mt
+					break
+				}
+			}
+		}
+	}
+	body, err := rule.OpenAPIRequestBody(formName, mediaType)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	err = node.Encode(body)
+	if err != nil {
+		return err
+	}
+	setMappingValue(operation, "requestBody", &node)
+	return nil
+}
+
+// updateParameters replaces the parameters of the operation which have
+// the same names and locations as the generated ones, and adds the rest.
+func updateParameters(generate func(string) ([]interface{}, error), operation *yaml.Node, formName string) error {
+	parameters, err := generate(formName)
+	if err != nil {
+		return err
+	}
+	existing := mappingValue(operation, "parameters")
+	if len(parameters) == 0 && existing == nil {
+		return nil
+	}
+	// a parameter is identified by the pair of its name and location
+	generated := make(map[string]bool, len(parameters))
+	for _, p := range parameters {
+		parameter := p.(map[string]interface{})
+		generated[parameterKey(parameter["name"].(string), parameter["in"].(string))] = true
+	}
+
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if existing != nil && existing.Kind == yaml.SequenceNode {
+		for _, p := range existing.Content {
+			name := mappingValue(p, "name")
+			in := mappingValue(p, "in")
+			if name != nil && in != nil && generated[parameterKey(name.Value, in.Value)] {
+				continue
+			}
+			list.Content = append(list.Content, p)
+		}
+	}
+	for _, p := range parameters {
+		var node yaml.Node
+		err = node.Encode(p)
+		if err != nil {
+			return err
+		}
+		list.Content = append(list.Content, &node)
+	}
+	setMappingValue(operation, "parameters", list)
+	return nil
+}
+
+func parameterKey(name, in string) string {
+	// header names are case insensitive
+	if in == "header" {
+		name = strings.ToLower(name)
+	}
+	return in + ":" + name
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value)
+}
+
+// writeJSON writes the node as JSON, keeping the order of the keys.
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, node.Content[0], indent)
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias, indent)
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			buf.WriteString(indent + "  ")
+			writeJSONString(buf, node.Content[i].Value)
+			buf.WriteString(": ")
+			err := writeJSON(buf, node.Content[i+1], indent+"  ")
+			if err != nil {
+				return err
+			}
+			if i+2 < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range node.Content {
+			buf.WriteString(indent + "  ")
+			err := writeJSON(buf, item, indent+"  ")
+			if err != nil {
+				return err
+			}
+			if i+1 < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "]")
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			buf.WriteString("null")
+		case "!!bool":
+			b, err := strconv.ParseBool(node.Value)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(strconv.FormatBool(b))
+		case "!!int", "!!float":
+			if !json.Valid([]byte(node.Value)) {
+				return fmt.Errorf("Number '%s' can't be written as JSON", node.Value)
+			}
+			buf.WriteString(node.Value)
+		default:
+			writeJSONString(buf, node.Value)
+		}
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	buf.Write(bytes.TrimRight(b.Bytes(), "\n"))
+}
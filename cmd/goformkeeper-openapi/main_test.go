@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestUpdateSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goformkeeper-openapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "spec.yml")
+	err = run("../../tests/schema.yml", "testdata/spec.yml", output)
+	if err != nil {
+		t.Errorf("Failed to update spec: %s", err.Error())
+		return
+	}
+
+	data, _ := ioutil.ReadFile(output)
+	if !strings.Contains(string(data), "# this comment should be kept") {
+		t.Errorf("comments should be kept")
+	}
+
+	var spec map[string]interface{}
+	yaml.Unmarshal(data, &spec)
+	paths := spec["paths"].(map[string]interface{})
+
+	post := paths["/signup"].(map[string]interface{})["post"].(map[string]interface{})
+	content := post["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+	if _, found := content["multipart/form-data"]; !found {
+		t.Errorf("media type of the existing requestBody should be kept: %v", content)
+	}
+
+	get := paths["/search"].(map[string]interface{})["get"].(map[string]interface{})
+	parameters := get["parameters"].([]interface{})
+	if len(parameters) != 6 {
+		t.Errorf("query parameters should be replaced, and path parameters kept: %v", parameters)
+		return
+	}
+	if parameters[0].(map[string]interface{})["in"] != "path" {
+		t.Errorf("path parameter should be kept first: %v", parameters[0])
+	}
+
+	tenant := paths["/tenants/{tenant}/signup"].(map[string]interface{})
+	tenantPost := tenant["post"].(map[string]interface{})
+	properties := tenantPost["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/x-www-form-urlencoded"].(map[string]interface{})["schema"].(map[string]interface{})["properties"].(map[string]interface{})
+	if len(properties) != 1 || properties["email"] == nil {
+		t.Errorf("requestBody should have only the fields in the body: %v", properties)
+	}
+	if got := parameterNames(tenantPost["parameters"]); got != "path:tenant,header:X-Token" {
+		t.Errorf("fields out of the body should be parameters of a POST: %s", got)
+	}
+	if got := parameterNames(tenant["get"].(map[string]interface{})["parameters"]); got != "path:tenant,header:X-Token,query:email" {
+		t.Errorf("header parameter should be replaced regardless of the case of its name: %s", got)
+	}
+
+	// running again gives the same result
+	output2 := filepath.Join(dir, "spec2.yml")
+	err = run("../../tests/schema.yml", output, output2)
+	if err != nil {
+		t.Errorf("Failed to update spec: %s", err.Error())
+		return
+	}
+	data2, _ := ioutil.ReadFile(output2)
+	if string(data) != string(data2) {
+		t.Errorf("updating should be idempotent")
+	}
+}
+
+func parameterNames(parameters interface{}) string {
+	names := make([]string, 0)
+	list, _ := parameters.([]interface{})
+	for _, p := range list {
+		parameter := p.(map[string]interface{})
+		names = append(names, fmt.Sprintf("%s:%s", parameter["in"], parameter["name"]))
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,258 @@
+package goformkeeper
+
+import (
+	"errors"
+	"fmt"
+)
+
+// validationContext carries what a constraint may look at besides the
+// value under validation: the keeper and the filtered values of the other
+// fields and selections of the form. rejected has the names of the ones
+// which failed before or in filters, and so have no filtered value.
+type validationContext struct {
+	keeper     *Keeper
+	fields     map[string]string
+	selections map[string][]string
+	rejected   map[string]bool
+}
+
+func newValidationContext(keeper *Keeper) *validationContext {
+	return &validationContext{
+		keeper:     keeper,
+		fields:     make(map[string]string),
+		selections: make(map[string][]string),
+		rejected:   make(map[string]bool),
+	}
+}
+
+// check validates value against the constraint. It returns nil when the
+// value passes, or the ConstraintFailure describing why it didn't. The
+// caller sets Index of the failure, as check doesn't know where the
+// constraint is written.
+//
+// Besides the validators registered on the keeper, the following composite
+// types are handled here. They nest a list of constraints under
+// 'constraints'.
+//
+//	any_of  passes when at least one of the constraints passes
+//	all_of  passes when all of the constraints pass
+//	not     passes when not all of the constraints pass
+//	when    checks the constraints only when the value of the field named
+//	        by the criteria 'field' passes all of the constraints in 'if'
+//
+// The 'expr' type, which evaluates the expression in the criteria 'expr'
+// (see expr.go), is handled here too, as it looks at the other fields.
+func (ctx *validationContext) check(value string, constraint *Constraint) (*ConstraintFailure, error) {
+	switch constraint.Type {
+	case "any_of":
+		if len(constraint.Constraints) == 0 {
+			return nil, errors.New("Constraints for 'any_of' not found")
+		}
+		inner := make([]*ConstraintFailure, 0)
+		for i, c := range constraint.Constraints {
+			failure, err := ctx.check(value, c)
+			if err != nil {
+				return nil, err
+			}
+			if failure == nil {
+				return nil, nil
+			}
+			failure.Index = i
+			inner = append(inner, failure)
+		}
+		return newCompositeFailure(constraint, inner), nil
+	case "all_of":
+		inner, err := ctx.checkAll(value, constraint.Constraints)
+		if err != nil {
+			return nil, err
+		}
+		if len(inner) > 0 {
+			return newCompositeFailure(constraint, inner), nil
+		}
+		return nil, nil
+	case "not":
+		if len(constraint.Constraints) == 0 {
+			return nil, errors.New("Constraints for 'not' not found")
+		}
+		inner, err := ctx.checkAll(value, constraint.Constraints)
+		if err != nil {
+			return nil, err
+		}
+		if len(inner) == 0 {
+			return newCompositeFailure(constraint, nil), nil
+		}
+		return nil, nil
+	case "when":
+		matched, err := ctx.matchCondition(value, constraint)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			return nil, nil
+		}
+		inner, err := ctx.checkAll(value, constraint.Constraints)
+		if err != nil {
+			return nil, err
+		}
+		if len(inner) > 0 {
+			return newCompositeFailure(constraint, inner), nil
+		}
+		return nil, nil
+	}
+	var pass bool
+	var err error
+	if constraint.Type == "expr" {
+		program := constraint.program
+		if program == nil {
+			program, err = compileConstraintExpr(constraint)
+			if err != nil {
+				return nil, err
+			}
+		}
+		pass, err = program.run(value, ctx.fields, ctx.selections)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pass, err = ctx.keeper.validate(value, constraint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pass {
+		return nil, nil
+	}
+	return &ConstraintFailure{
+		ConstraintType: constraint.Type,
+		Message:        constraint.Message,
+		ID:             constraint.ID,
+	}, nil
+}
+
+func (ctx *validationContext) checkAll(value string, constraints []*Constraint) ([]*ConstraintFailure, error) {
+	failures := make([]*ConstraintFailure, 0)
+	for i, c := range constraints {
+		failure, err := ctx.check(value, c)
+		if err != nil {
+			return nil, err
+		}
+		if failure != nil {
+			failure.Index = i
+			failures = append(failures, failure)
+		}
+	}
+	return failures, nil
+}
+
+// matchCondition tells whether the condition of a 'when' constraint holds.
+// The value checked is the one of the field named by the criteria 'field',
+// or the value under validation itself when it's omitted. When 'field'
+// names a selection, the condition holds if any of its values passes.
+// It doesn't hold when the field failed before or in filters.
+func (ctx *validationContext) matchCondition(value string, constraint *Constraint) (bool, error) {
+	criteria := &Criteria{constraint.Criteria}
+	values := []string{value}
+	if criteria.Has("field") {
+		name, err := criteria.String("field")
+		if err != nil {
+			return false, err
+		}
+		if ctx.rejected[name] {
+			return false, nil
+		}
+		if v, found := ctx.fields[name]; found {
+			values = []string{v}
+		} else if vs, found := ctx.selections[name]; found {
+			values = vs
+		} else {
+			return false, fmt.Errorf("Field for 'when' not found: %s", name)
+		}
+	}
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		failures, err := ctx.checkAll(v, constraint.If)
+		if err != nil {
+			return false, err
+		}
+		if len(failures) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newCompositeFailure(constraint *Constraint, inner []*ConstraintFailure) *ConstraintFailure {
+	return &ConstraintFailure{
+		ConstraintType: constraint.Type,
+		Message:        constraint.Message,
+		ID:             constraint.ID,
+		Inner:          inner,
+	}
+}
+
+func compileConstraintExpr(constraint *Constraint) (*exprProgram, error) {
+	criteria := &Criteria{constraint.Criteria}
+	if !criteria.Has("expr") {
+		return nil, errors.New("Criteria for 'expr' not enough")
+	}
+	source, err := criteria.String("expr")
+	if err != nil {
+		return nil, err
+	}
+	maxCost := 0
+	if criteria.Has("max_cost") {
+		maxCost, err = criteria.Int("max_cost")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compileExpr(source, maxCost)
+}
+
+// checkConditionFields checks every 'when' constraint looks at a field or a selection in names.
+func checkConditionFields(constraints []*Constraint, names map[string]bool) error {
+	for _, constraint := range constraints {
+		if constraint.Type == "when" {
+			criteria := &Criteria{constraint.Criteria}
+			if criteria.Has("field") {
+				name, err := criteria.String("field")
+				if err != nil {
+					return err
+				}
+				if !names[name] {
+					return fmt.Errorf("Field for 'when' not found: %s", name)
+				}
+			}
+		}
+		if err := checkConditionFields(constraint.Constraints, names); err != nil {
+			return err
+		}
+		if err := checkConditionFields(constraint.If, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileConstraints prepares the constraints which need it, such as
+// 'expr', so that errors in a rule file are reported when it's loaded.
+func compileConstraints(constraints []*Constraint) error {
+	for _, constraint := range constraints {
+		if constraint.Type == "expr" {
+			program, err := compileConstraintExpr(constraint)
+			if err != nil {
+				return err
+			}
+			constraint.program = program
+		}
+		if err := compileConstraints(constraint.Constraints); err != nil {
+			return err
+		}
+		if err := compileConstraints(constraint.If); err != nil {
+			return err
+		}
+	}
+	return nil
+}
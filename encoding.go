@@ -0,0 +1,72 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// EncodingStrict is the value of Form.Encoding which makes the values
+// fail before filters run when they have invalid UTF-8 or characters no
+// form input needs. Each is reported with the constraint type below.
+//
+//	invalid_utf8       invalid UTF-8, including encoded surrogates
+//	null_character     U+0000
+//	control_character  C0 and C1 controls and DEL, except tab, LF and CR
+//	bidi_character     bidirectional formatting characters, which can
+//	                   make text look different from what it is
+const EncodingStrict = "strict"
+
+func checkEncoding(encoding string) error {
+	switch encoding {
+	case "", EncodingStrict:
+		return nil
+	}
+	return fmt.Errorf("Unknown encoding '%s'", encoding)
+}
+
+// encodingViolation returns the constraint type the first bad value
+// violates, or "" when the form doesn't check the encoding.
+func (form *Form) encodingViolation(values []string) string {
+	if form.Encoding != EncodingStrict {
+		return ""
+	}
+	for _, value := range values {
+		if violation := strictEncodingViolation(value); violation != "" {
+			return violation
+		}
+	}
+	return ""
+}
+
+// strictEncodingViolation returns the constraint type the value violates
+// in the strict encoding, or "" when it has no problem.
+func strictEncodingViolation(value string) string {
+	if !utf8.ValidString(value) {
+		return "invalid_utf8"
+	}
+	for _, r := range value {
+		switch {
+		case r == 0:
+			return "null_character"
+		case r == '\t' || r == '\n' || r == '\r':
+			// allowed in textarea
+		case r < 0x20 || (r >= 0x7F && r <= 0x9F):
+			return "control_character"
+		case isBidiCharacter(r):
+			return "bidi_character"
+		}
+	}
+	return ""
+}
+
+func isBidiCharacter(r rune) bool {
+	switch {
+	case r == 0x061C, r == 0x200E, r == 0x200F:
+		return true
+	case r >= 0x202A && r <= 0x202E:
+		return true
+	case r >= 0x2066 && r <= 0x2069:
+		return true
+	}
+	return false
+}
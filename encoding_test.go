@@ -0,0 +1,59 @@
+package goformkeeper
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStrictEncodingViolation(t *testing.T) {
+	cases := map[string]string{
+		"hello":               "",
+		"こんにちは":               "",
+		"line1\r\nline2\tend": "",
+		"\xff\xfe":            "invalid_utf8",
+		"\xed\xa0\x80":        "invalid_utf8",
+		"foo\x00bar":          "null_character",
+		"foo\x1bbar":          "control_character",
+		"foo\x7fbar":          "control_character",
+		"foo\u0085bar":        "control_character",
+		"abc\u202etxt.exe":    "bidi_character",
+		"\u2066foo\u2069":     "bidi_character",
+	}
+	for value, want := range cases {
+		if got := strictEncodingViolation(value); got != want {
+			t.Errorf("%q: want %q, got %q", value, want, got)
+		}
+	}
+}
+
+func TestStrictEncoding(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/encoding.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?title=foo%00bar&body=%FF&tag=go&tag=%E2%80%AEgo")
+	req.URL = url
+
+	result, err := rule.Validate("post", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	// filters don't run before the check
+	if !result.FailedOnConstraint("title", "null_character") || result.MessageOn("title") != "Input title" {
+		t.Errorf("title should fail on null_character")
+	}
+	if !result.FailedOnConstraint("body", "invalid_utf8") {
+		t.Errorf("body should fail on invalid_utf8")
+	}
+	if !result.FailedOnConstraint("tag", "bidi_character") {
+		t.Errorf("tag should fail on bidi_character")
+	}
+}
@@ -0,0 +1,856 @@
+package goformkeeper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file implements the small expression language used by the 'expr'
+// constraint.
+//
+//	- type: expr
+//	  criteria:
+//	    expr: "int(fields.qty) * int(fields.price) <= 100000"
+//
+// An expression can refer to 'value' (the filtered value under validation),
+// 'fields' and 'selections' (the filtered values of the form). It supports
+// int, float, string and bool literals, the operators
+//
+//	||  &&  ==  !=  <  <=  >  >=  +  -  *  /  %  !
+//
+// member access (fields.name), indexing (fields["name"], selections.a[0])
+// and the functions listed in exprFuncs. There are no loops or assignments.
+//
+// Every step of evaluation is charged against a cost budget, and the
+// operations and the functions which go through strings are charged by
+// length too, so an expression can't take unbounded time however large
+// the submitted value is. Exceeding the budget is an error, rather than
+// a failure of the value, as it tells the budget is too small for the
+// values the rule accepts.
+
+const defaultExprMaxCost = 10000
+
+var errExprCostExceeded = errors.New("Expression exceeded the cost limit, raise 'max_cost'")
+
+type exprProgram struct {
+	source  string
+	root    exprNode
+	maxCost int
+}
+
+type exprEnv struct {
+	vars map[string]interface{}
+	cost int
+	max  int
+}
+
+func (env *exprEnv) charge(cost int) error {
+	env.cost += cost
+	if env.cost > env.max {
+		return errExprCostExceeded
+	}
+	return nil
+}
+
+func compileExpr(source string, maxCost int) (*exprProgram, error) {
+	p := &exprParser{lexer: &exprLexer{source: source}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != exprTokenEOF {
+		return nil, fmt.Errorf("Unexpected '%s' at %d in expression", p.token.text, p.token.pos)
+	}
+	if maxCost <= 0 {
+		maxCost = defaultExprMaxCost
+	}
+	return &exprProgram{source: source, root: root, maxCost: maxCost}, nil
+}
+
+// run evaluates the program, and tells whether it resulted in true.
+// Errors raised by the values, such as int("abc") or a division by zero,
+// make it false as well, since they depend on what the user submitted.
+// Exceeding the cost limit is returned as an error.
+func (prog *exprProgram) run(value string, fields map[string]string, selections map[string][]string) (bool, error) {
+	fs := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		fs[k] = v
+	}
+	ss := make(map[string]interface{}, len(selections))
+	for k, v := range selections {
+		ss[k] = v
+	}
+	env := &exprEnv{
+		vars: map[string]interface{}{
+			"value":      value,
+			"fields":     fs,
+			"selections": ss,
+		},
+		max: prog.maxCost,
+	}
+	result, err := prog.root.eval(env)
+	if err == errExprCostExceeded {
+		return false, err
+	}
+	if err != nil {
+		return false, nil
+	}
+	b, ok := result.(bool)
+	return ok && b, nil
+}
+
+// lexer
+
+type exprTokenKind int
+
+const (
+	exprTokenEOF exprTokenKind = iota
+	exprTokenInt
+	exprTokenFloat
+	exprTokenString
+	exprTokenIdent
+	exprTokenOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+type exprLexer struct {
+	source string
+	pos    int
+}
+
+var exprOperators = []string{
+	"||", "&&", "==", "!=", "<=", ">=",
+	"<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ".", ",",
+}
+
+func (l *exprLexer) next() (exprToken, error) {
+	for l.pos < len(l.source) && (l.source[l.pos] == ' ' || l.source[l.pos] == '\t' || l.source[l.pos] == '\n' || l.source[l.pos] == '\r') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.source) {
+		return exprToken{kind: exprTokenEOF, pos: start}, nil
+	}
+	c := l.source[l.pos]
+	switch {
+	case c >= '0' && c <= '9':
+		kind := exprTokenInt
+		for l.pos < len(l.source) && (isDigitByte(l.source[l.pos]) || l.source[l.pos] == '.') {
+			if l.source[l.pos] == '.' {
+				if kind == exprTokenFloat {
+					break
+				}
+				kind = exprTokenFloat
+			}
+			l.pos++
+		}
+		return exprToken{kind: kind, text: l.source[start:l.pos], pos: start}, nil
+	case c == '"' || c == '\'':
+		l.pos++
+		escaped := false
+		for l.pos < len(l.source) {
+			ch := l.source[l.pos]
+			l.pos++
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == c {
+				text := l.source[start:l.pos]
+				if c == '\'' {
+					text = "\"" + strings.Replace(strings.Replace(text[1:len(text)-1], "\\'", "'", -1), "\"", "\\\"", -1) + "\""
+				}
+				s, err := strconv.Unquote(text)
+				if err != nil {
+					return exprToken{}, fmt.Errorf("Invalid string literal at %d in expression", start)
+				}
+				return exprToken{kind: exprTokenString, text: s, pos: start}, nil
+			}
+		}
+		return exprToken{}, fmt.Errorf("Unterminated string literal at %d in expression", start)
+	case c == '_' || isLetterByte(c):
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetterByte(l.source[l.pos]) || isDigitByte(l.source[l.pos])) {
+			l.pos++
+		}
+		return exprToken{kind: exprTokenIdent, text: l.source[start:l.pos], pos: start}, nil
+	}
+	for _, op := range exprOperators {
+		if strings.HasPrefix(l.source[l.pos:], op) {
+			l.pos += len(op)
+			return exprToken{kind: exprTokenOp, text: op, pos: start}, nil
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(l.source[l.pos:])
+	return exprToken{}, fmt.Errorf("Unexpected character '%c' at %d in expression", r, start)
+}
+
+func isDigitByte(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetterByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parser
+
+type exprParser struct {
+	lexer *exprLexer
+	token exprToken
+}
+
+func (p *exprParser) next() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *exprParser) expect(op string) error {
+	if p.token.kind != exprTokenOp || p.token.text != op {
+		return fmt.Errorf("Expected '%s' at %d in expression", op, p.token.pos)
+	}
+	return p.next()
+}
+
+var exprBinaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+func (p *exprParser) parseExpr(minPrecedence int) (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.token.kind == exprTokenOp {
+		op := p.token.text
+		precedence, found := exprBinaryPrecedence[op]
+		if !found || precedence <= minPrecedence {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseExpr(precedence)
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.token.kind == exprTokenOp && (p.token.text == "!" || p.token.text == "-") {
+		op := p.token.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.token.kind == exprTokenOp {
+		switch p.token.text {
+		case ".":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.token.kind != exprTokenIdent {
+				return nil, fmt.Errorf("Expected a name after '.' at %d in expression", p.token.pos)
+			}
+			node = &exprIndex{target: node, index: &exprLiteral{value: p.token.text}}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case "[":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			index, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &exprIndex{target: node, index: index}
+		default:
+			return node, nil
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	token := p.token
+	switch token.kind {
+	case exprTokenInt:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		i, err := strconv.ParseInt(token.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number '%s' at %d in expression", token.text, token.pos)
+		}
+		return &exprLiteral{value: i}, nil
+	case exprTokenFloat:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number '%s' at %d in expression", token.text, token.pos)
+		}
+		return &exprLiteral{value: f}, nil
+	case exprTokenString:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return &exprLiteral{value: token.text}, nil
+	case exprTokenIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch token.text {
+		case "true":
+			return &exprLiteral{value: true}, nil
+		case "false":
+			return &exprLiteral{value: false}, nil
+		}
+		if p.token.kind == exprTokenOp && p.token.text == "(" {
+			return p.parseCall(token)
+		}
+		switch token.text {
+		case "value", "fields", "selections":
+			return &exprVar{name: token.text}, nil
+		}
+		return nil, fmt.Errorf("Unknown name '%s' at %d in expression", token.text, token.pos)
+	case exprTokenOp:
+		if token.text == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			node, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+	case exprTokenEOF:
+		return nil, errors.New("Unexpected end of expression")
+	}
+	return nil, fmt.Errorf("Unexpected '%s' at %d in expression", token.text, token.pos)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	f, found := exprFuncs[name.text]
+	if !found {
+		return nil, fmt.Errorf("Unknown function '%s' at %d in expression", name.text, name.pos)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := make([]exprNode, 0)
+	for !(p.token.kind == exprTokenOp && p.token.text == ")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if len(args) != f.arity {
+		return nil, fmt.Errorf("Function '%s' takes %d arguments at %d in expression", name.text, f.arity, name.pos)
+	}
+	return &exprCall{name: name.text, f: f, args: args}, nil
+}
+
+// nodes
+
+type exprNode interface {
+	eval(env *exprEnv) (interface{}, error)
+}
+
+type exprLiteral struct {
+	value interface{}
+}
+
+func (n *exprLiteral) eval(env *exprEnv) (interface{}, error) {
+	if err := env.charge(1); err != nil {
+		return nil, err
+	}
+	return n.value, nil
+}
+
+type exprVar struct {
+	name string
+}
+
+func (n *exprVar) eval(env *exprEnv) (interface{}, error) {
+	if err := env.charge(1); err != nil {
+		return nil, err
+	}
+	return env.vars[n.name], nil
+}
+
+type exprIndex struct {
+	target exprNode
+	index  exprNode
+}
+
+func (n *exprIndex) eval(env *exprEnv) (interface{}, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.charge(1); err != nil {
+		return nil, err
+	}
+	switch t := target.(type) {
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, errors.New("Map index should be a string")
+		}
+		v, found := t[key]
+		if !found {
+			return "", nil
+		}
+		return v, nil
+	case []string:
+		i, ok := index.(int64)
+		if !ok {
+			return nil, errors.New("List index should be an int")
+		}
+		if i < 0 || i >= int64(len(t)) {
+			return nil, errors.New("List index out of range")
+		}
+		return t[i], nil
+	}
+	return nil, errors.New("Value can't be indexed")
+}
+
+// errIntOverflow is returned when an arithmetic on ints doesn't fit in
+// int64, rather than letting the result wrap around.
+var errIntOverflow = errors.New("Integer overflow")
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (n *exprUnary) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.charge(1); err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("Operand of '!' should be a bool")
+		}
+		return !b, nil
+	case "-":
+		switch x := v.(type) {
+		case int64:
+			if x == math.MinInt64 {
+				return nil, errIntOverflow
+			}
+			return -x, nil
+		case float64:
+			return -x, nil
+		}
+		return nil, errors.New("Operand of '-' should be a number")
+	}
+	return nil, fmt.Errorf("Unknown operator '%s'", n.op)
+}
+
+type exprBinary struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (n *exprBinary) eval(env *exprEnv) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("Operand of '%s' should be a bool", n.op)
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("Operand of '%s' should be a bool", n.op)
+		}
+		return r, nil
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.charge(1); err != nil {
+		return nil, err
+	}
+
+	if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("Operands of '%s' should have the same type", n.op)
+		}
+		if err := env.charge((len(l) + len(r)) / 64); err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "+":
+			return l + r, nil
+		case "==":
+			return l == r, nil
+		case "!=":
+			return l != r, nil
+		case "<":
+			return l < r, nil
+		case "<=":
+			return l <= r, nil
+		case ">":
+			return l > r, nil
+		case ">=":
+			return l >= r, nil
+		}
+		return nil, fmt.Errorf("Operator '%s' can't be used for strings", n.op)
+	}
+
+	if l, ok := left.(bool); ok {
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("Operands of '%s' should have the same type", n.op)
+		}
+		switch n.op {
+		case "==":
+			return l == r, nil
+		case "!=":
+			return l != r, nil
+		}
+		return nil, fmt.Errorf("Operator '%s' can't be used for bools", n.op)
+	}
+
+	li, lIsInt := left.(int64)
+	ri, rIsInt := right.(int64)
+	if lIsInt && rIsInt {
+		switch n.op {
+		case "+":
+			r := li + ri
+			if (r > li) != (ri > 0) {
+				return nil, errIntOverflow
+			}
+			return r, nil
+		case "-":
+			r := li - ri
+			if (r < li) != (ri > 0) {
+				return nil, errIntOverflow
+			}
+			return r, nil
+		case "*":
+			r := li * ri
+			if (li != 0 && r/li != ri) || (li == -1 && ri == math.MinInt64) {
+				return nil, errIntOverflow
+			}
+			return r, nil
+		case "/":
+			if ri == 0 {
+				return nil, errors.New("Division by zero")
+			}
+			if li == math.MinInt64 && ri == -1 {
+				return nil, errIntOverflow
+			}
+			return li / ri, nil
+		case "%":
+			if ri == 0 {
+				return nil, errors.New("Division by zero")
+			}
+			return li % ri, nil
+		}
+		return compareNumbers(n.op, float64(li), float64(ri))
+	}
+
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("Operands of '%s' should be numbers", n.op)
+	}
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errors.New("Division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		return nil, errors.New("Operator '%' can't be used for floats")
+	}
+	return compareNumbers(n.op, lf, rf)
+}
+
+func compareNumbers(op string, l, r float64) (interface{}, error) {
+	switch op {
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("Unknown operator '%s'", op)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+type exprCall struct {
+	name string
+	f    *exprFunc
+	args []exprNode
+}
+
+func (n *exprCall) eval(env *exprEnv) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	cost := 1
+	if n.f.linear {
+		cost += argsLength(args) / 64
+	}
+	if err := env.charge(cost); err != nil {
+		return nil, err
+	}
+	return n.f.call(args)
+}
+
+// argsLength is the total length of the strings in the arguments,
+// including the values of selections.
+func argsLength(args []interface{}) int {
+	length := 0
+	for _, arg := range args {
+		switch x := arg.(type) {
+		case string:
+			length += len(x)
+		case []string:
+			for _, s := range x {
+				length += len(s) + 1
+			}
+		}
+	}
+	return length
+}
+
+// exprFunc is a function of expressions. A linear one goes through its
+// arguments, and is charged by their length.
+type exprFunc struct {
+	arity  int
+	linear bool
+	call   func(args []interface{}) (interface{}, error)
+}
+
+var exprFuncs = map[string]*exprFunc{
+	// len returns the length of a string in bytes, or of a selection
+	"len": {1, false, func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case string:
+			return int64(len(x)), nil
+		case []string:
+			return int64(len(x)), nil
+		}
+		return nil, errors.New("Argument of 'len' should be a string or a selection")
+	}},
+	// runes returns the number of characters in a string
+	"runes": {1, true, func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("Argument of 'runes' should be a string")
+		}
+		return int64(utf8.RuneCountInString(s)), nil
+	}},
+	"int": {1, true, func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case string:
+			return strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		case int64:
+			return x, nil
+		case float64:
+			return int64(x), nil
+		}
+		return nil, errors.New("Argument of 'int' can't be converted")
+	}},
+	"float": {1, true, func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(x), 64)
+		case int64:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		}
+		return nil, errors.New("Argument of 'float' can't be converted")
+	}},
+	"string": {1, false, func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case string:
+			return x, nil
+		case int64:
+			return strconv.FormatInt(x, 10), nil
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(x), nil
+		}
+		return nil, errors.New("Argument of 'string' can't be converted")
+	}},
+	"lower": {1, true, func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("Argument of 'lower' should be a string")
+		}
+		return strings.ToLower(s), nil
+	}},
+	"upper": {1, true, func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("Argument of 'upper' should be a string")
+		}
+		return strings.ToUpper(s), nil
+	}},
+	// contains tells whether a string contains a substring, or whether
+	// a selection contains a value
+	"contains": {2, true, func(args []interface{}) (interface{}, error) {
+		sub, ok := args[1].(string)
+		if !ok {
+			return nil, errors.New("Second argument of 'contains' should be a string")
+		}
+		switch x := args[0].(type) {
+		case string:
+			return strings.Contains(x, sub), nil
+		case []string:
+			for _, v := range x {
+				if v == sub {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return nil, errors.New("First argument of 'contains' should be a string or a selection")
+	}},
+	"matches": {2, true, func(args []interface{}) (interface{}, error) {
+		s, ok1 := args[0].(string)
+		pattern, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, errors.New("Arguments of 'matches' should be strings")
+		}
+		return regexp.MatchString(pattern, s)
+	}},
+	"empty": {1, false, func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case string:
+			return x == "", nil
+		case []string:
+			return len(x) == 0, nil
+		}
+		return nil, errors.New("Argument of 'empty' should be a string or a selection")
+	}},
+	"is_digit": {1, true, func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("Argument of 'is_digit' should be a string")
+		}
+		if s == "" {
+			return false, nil
+		}
+		for _, r := range s {
+			if !unicode.IsDigit(r) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}},
+}
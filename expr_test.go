@@ -0,0 +1,123 @@
+package goformkeeper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	fields := map[string]string{"qty": "3", "price": "1000", "country": "JP"}
+	selections := map[string][]string{"hobby": {"music", "sports"}}
+
+	cases := []struct {
+		source string
+		value  string
+		want   bool
+	}{
+		{"len(value) % 4 == 0", "abcd", true},
+		{"len(value) % 4 == 0", "abc", false},
+		{"int(fields.qty) * int(fields.price) <= 100000", "", true},
+		{"int(fields.qty) * int(fields.price) > 100000", "", false},
+		{"fields[\"country\"] == 'JP' && runes(value) <= 3", "あいう", true},
+		{"!(fields.country != \"JP\") || false", "", true},
+		{"contains(selections.hobby, 'music') && len(selections.hobby) == 2", "", true},
+		{"selections.hobby[1] == 'sports'", "", true},
+		{"float(value) / 2 >= 1.25", "2.5", true},
+		{"-int(value) + 1 == -1", "2", true},
+		{"matches(value, '^[0-9]+$')", "123", true},
+		{"int(value) > 0", "abc", false},
+		{"int(value) / 0 == 0", "1", false},
+		{"value", "x", false},
+		{"empty(fields.unknown)", "", true},
+		{"int(value) * 4 <= 100000", "4611686018427387904", false},
+		{"int(value) * 4 > 100000", "4611686018427387904", false},
+		{"int(value) + 1 > 0", "9223372036854775807", false},
+		{"int(value) - 1 < 0", "-9223372036854775808", false},
+		{"-int(value) > 0", "-9223372036854775808", false},
+		{"int(value) / -1 > 0", "-9223372036854775808", false},
+		{"int(value) * -1 < 0", "9223372036854775807", true},
+	}
+
+	for _, c := range cases {
+		program, err := compileExpr(c.source, 0)
+		if err != nil {
+			t.Errorf("Failed to compile '%s': %s", c.source, err.Error())
+			continue
+		}
+		got, err := program.run(c.value, fields, selections)
+		if err != nil {
+			t.Errorf("'%s' with value '%s': %s", c.source, c.value, err.Error())
+			continue
+		}
+		if got != c.want {
+			t.Errorf("'%s' with value '%s': want %v, got %v", c.source, c.value, c.want, got)
+		}
+	}
+}
+
+func TestExprCompileError(t *testing.T) {
+	sources := []string{
+		"len(value",
+		"len(value) ==",
+		"unknown(value)",
+		"len(value, value)",
+		"foo == 1",
+		"'unterminated",
+		"value # 1",
+	}
+	for _, source := range sources {
+		if _, err := compileExpr(source, 0); err == nil {
+			t.Errorf("'%s' should fail to compile", source)
+		}
+	}
+}
+
+func TestExprCostLimit(t *testing.T) {
+	program, err := compileExpr("len(value + value) >= 0", 100)
+	if err != nil {
+		t.Errorf("Failed to compile: %s", err.Error())
+		return
+	}
+	if ok, err := program.run("short", nil, nil); !ok || err != nil {
+		t.Errorf("short value should be evaluated")
+	}
+	if _, err := program.run(strings.Repeat("a", 100000), nil, nil); err != errExprCostExceeded {
+		t.Errorf("evaluation should be stopped by the cost limit")
+	}
+}
+
+func TestExprCostOfFunctions(t *testing.T) {
+	value := strings.Repeat("a", 1<<20)
+	cases := []struct {
+		source string
+		want   bool
+	}{
+		{"len(value) % 4 == 0", true},
+		{"!empty(value)", true},
+		{"len(string(value)) > 0", true},
+	}
+	for _, c := range cases {
+		program, err := compileExpr(c.source, 0)
+		if err != nil {
+			t.Errorf("Failed to compile '%s': %s", c.source, err.Error())
+			continue
+		}
+		got, err := program.run(value, nil, nil)
+		if err != nil {
+			t.Errorf("'%s' shouldn't be charged by the length: %s", c.source, err.Error())
+			continue
+		}
+		if got != c.want {
+			t.Errorf("'%s': want %v, got %v", c.source, c.want, got)
+		}
+	}
+
+	program, err := compileExpr("runes(value) > 0", 0)
+	if err != nil {
+		t.Errorf("Failed to compile: %s", err.Error())
+		return
+	}
+	if _, err := program.run(value, nil, nil); err != errExprCostExceeded {
+		t.Errorf("'runes' should be charged by the length")
+	}
+}
@@ -1,38 +1,495 @@
 package goformkeeper
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type FilterRule interface {
-	GetFilterNames() []string
+	GetFilters() []*FilterSpec
+}
+
+// FilterSpec is one entry of 'filters' in a rule file. It's written either
+// as a plain filter name, or as a mapping with 'name', 'message' and 'args'.
+//
+//	filters:
+//	  - trim
+//	  - name: truncate
+//	    args:
+//	      runes: 100
+type FilterSpec struct {
+	Name    string
+	Message string
+	Args    map[string]interface{}
+}
+
+func (spec *FilterSpec) SetYAML(tag string, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		spec.Name = v
+		return true
+	case map[interface{}]interface{}:
+		for key, param := range v {
+			switch key {
+			case "name":
+				name, ok := param.(string)
+				if !ok {
+					return false
+				}
+				spec.Name = name
+			case "message":
+				message, ok := param.(string)
+				if !ok {
+					return false
+				}
+				spec.Message = message
+			case "args":
+				args, ok := param.(map[interface{}]interface{})
+				if !ok {
+					return false
+				}
+				spec.Args = make(map[string]interface{}, len(args))
+				for k, arg := range args {
+					name, ok := k.(string)
+					if !ok {
+						return false
+					}
+					spec.Args[name] = arg
+				}
+			}
+		}
+		return spec.Name != ""
+	}
+	return false
+}
+
+// Filter converts a submitted value before constraints are checked.
+// args holds the 'args' written for the filter in the rule file.
+//
+// A filter that finds the value itself unacceptable should return a
+// *FilterFailure, which is reported as a failure on the field. Any other
+// error is treated as a problem of the program, and Validate returns it.
+type Filter interface {
+	Filter(value string, args *Criteria) (string, error)
+}
+
+// FilterFunc adapts a plain string conversion function to Filter.
+type FilterFunc func(string) string
+
+func (f FilterFunc) Filter(value string, args *Criteria) (string, error) {
+	return f(value), nil
+}
+
+// FilterFailure tells that the submitted value was rejected by a filter.
+// On the Result it's recorded with the filter name as the constraint type.
+type FilterFailure struct {
+	FilterName string
+	Message    string
 }
 
-var filters map[string]func(string) string
+func (f *FilterFailure) Error() string {
+	return fmt.Sprintf("Filter '%s' rejected the value", f.FilterName)
+}
 
-func init() {
-	filters = make(map[string]func(string) string)
-	setDefaultFilters()
+func AddFilter(name string, f Filter) {
+	defaultKeeper.AddFilter(name, f)
 }
 
 func AddFilterFunc(funcName string, f func(string) string) {
-	filters[funcName] = f
+	defaultKeeper.AddFilterFunc(funcName, f)
+}
+
+func setDefaultFilters(k *Keeper) {
+	k.AddFilterFunc("trim", strings.TrimSpace)
+	k.AddFilterFunc("lowercase", strings.ToLower)
+	k.AddFilterFunc("uppercase", strings.ToUpper)
+	k.AddFilterFunc("trim_unicode", TrimUnicodeSpace)
+	k.AddFilterFunc("half_width_alnum", HalfWidthAlnum)
+	k.AddFilterFunc("full_width_alnum", FullWidthAlnum)
+	k.AddFilterFunc("half_width_ascii", HalfWidthASCII)
+	k.AddFilterFunc("full_width_ascii", FullWidthASCII)
+	k.AddFilterFunc("full_width_katakana", FullWidthKatakana)
+	k.AddFilterFunc("hiragana", KatakanaToHiragana)
+	k.AddFilterFunc("katakana", HiraganaToKatakana)
+	k.AddFilterFunc("nfc", norm.NFC.String)
+	k.AddFilterFunc("nfkc", norm.NFKC.String)
+	k.AddFilter("truncate", &TruncateFilter{})
+	k.AddFilter("replace", &ReplaceFilter{})
+	k.AddFilter("collapse_space", &CollapseSpaceFilter{})
+	k.AddFilter("normalize_newlines", &NormalizeNewlinesFilter{})
+	k.AddFilterFunc("strip_control", StripControl)
+	k.AddFilterFunc("strip_tags", StripTags)
+	k.AddFilter("squeeze", &SqueezeFilter{})
+}
+
+// TruncateFilter cuts the value down to 'runes' characters, or to 'bytes'
+// bytes without splitting a multibyte character.
+type TruncateFilter struct{}
+
+func (f *TruncateFilter) Filter(value string, args *Criteria) (string, error) {
+	if args.Has("runes") {
+		max, err := args.Int("runes")
+		if err != nil {
+			return "", err
+		}
+		count := 0
+		for i := range value {
+			if count == max {
+				return value[:i], nil
+			}
+			count++
+		}
+		return value, nil
+	} else if args.Has("bytes") {
+		max, err := args.Int("bytes")
+		if err != nil {
+			return "", err
+		}
+		end := 0
+		for end < len(value) {
+			_, size := utf8.DecodeRuneInString(value[end:])
+			if end+size > max {
+				break
+			}
+			end += size
+		}
+		return value[:end], nil
+	} else {
+		return "", errors.New("Args for 'truncate' not enough")
+	}
+}
+
+// ReplaceFilter replaces every match of the regular expression 'pattern'
+// with 'with'. Omitting 'with' removes the matches.
+type ReplaceFilter struct{}
+
+func (f *ReplaceFilter) Filter(value string, args *Criteria) (string, error) {
+	re, with, err := replaceArgs(args)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(value, with), nil
+}
+
+// replacePatterns holds the compiled patterns of 'replace' by their
+// sources, so that they aren't compiled for every value.
+var replacePatterns = struct {
+	sync.RWMutex
+	compiled map[string]*regexp.Regexp
+}{compiled: make(map[string]*regexp.Regexp)}
+
+func replaceArgs(args *Criteria) (*regexp.Regexp, string, error) {
+	if !args.Has("pattern") {
+		return nil, "", errors.New("Args for 'replace' not enough")
+	}
+	pattern, err := args.String("pattern")
+	if err != nil {
+		return nil, "", err
+	}
+	with := ""
+	if args.Has("with") {
+		with, err = args.String("with")
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	replacePatterns.RLock()
+	re, found := replacePatterns.compiled[pattern]
+	replacePatterns.RUnlock()
+	if found {
+		return re, with, nil
+	}
+	re, err = regexp.Compile(pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	replacePatterns.Lock()
+	replacePatterns.compiled[pattern] = re
+	replacePatterns.Unlock()
+	return re, with, nil
+}
+
+// compileFilters checks the args of the filters which need it, such as
+// 'replace', so that errors in a rule file are reported when it's loaded.
+func compileFilters(specs []*FilterSpec) error {
+	for _, spec := range specs {
+		if spec.Name == "replace" {
+			if _, _, err := replaceArgs(&Criteria{spec.Args}); err != nil {
+				return fmt.Errorf("Invalid args for filter 'replace': %s", err.Error())
+			}
+		}
+	}
+	return nil
 }
 
-func setDefaultFilters() {
-	AddFilterFunc("trim", strings.TrimSpace)
-	AddFilterFunc("lowercase", strings.ToLower)
-	AddFilterFunc("uppercase", strings.ToUpper)
+// CollapseSpaceFilter replaces each run of white space, including the
+// ideographic space, with a single ASCII space. Line breaks are kept
+// unless 'newlines' is true, so that it can be used for multi-line text.
+type CollapseSpaceFilter struct{}
+
+func (f *CollapseSpaceFilter) Filter(value string, args *Criteria) (string, error) {
+	newlines := false
+	if args.Has("newlines") {
+		b, err := args.Bool("newlines")
+		if err != nil {
+			return "", err
+		}
+		newlines = b
+	}
+	runes := make([]rune, 0, len(value))
+	inSpace := false
+	for _, r := range value {
+		if unicode.IsSpace(r) && (newlines || !isNewlineRune(r)) {
+			if !inSpace {
+				runes = append(runes, ' ')
+			}
+			inSpace = true
+			continue
+		}
+		inSpace = false
+		runes = append(runes, r)
+	}
+	return string(runes), nil
+}
+
+func isNewlineRune(r rune) bool {
+	return r == '\n' || r == '\r' || r == '\u0085' || r == '\u2028' || r == '\u2029'
 }
 
-func filter(filterRule FilterRule, value string) (string, error) {
-	for _, filterName := range filterRule.GetFilterNames() {
-		f, found := filters[filterName]
-		if !found {
-			return "", fmt.Errorf("Unknown filter %s", filterName)
+var newlinePattern = regexp.MustCompile(`\r\n|[\r\n\x{0085}\x{2028}\x{2029}]`)
+
+// NormalizeNewlinesFilter replaces CRLF, CR and the Unicode line
+// separators with LF, or with 'with' when it's given.
+type NormalizeNewlinesFilter struct{}
+
+func (f *NormalizeNewlinesFilter) Filter(value string, args *Criteria) (string, error) {
+	with := "\n"
+	if args.Has("with") {
+		s, err := args.String("with")
+		if err != nil {
+			return "", err
 		}
-		value = f(value)
+		with = s
 	}
-	return value, nil
+	return newlinePattern.ReplaceAllLiteralString(value, with), nil
+}
+
+// StripControl removes control characters other than tab and line breaks,
+// and invisible format characters such as zero width space, zero width
+// joiners, byte order mark and bidirectional overrides.
+func StripControl(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return r
+		}
+		if unicode.Is(unicode.Cc, r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// StripTags removes HTML tags and comments in a single pass. The contents
+// of script and style elements are removed together with the tags. A '<'
+// which doesn't start a tag is removed too, so that no tag is left, nor
+// made of the text around a removed one as in "<<a>img>". Character
+// references are left as they are.
+func StripTags(value string) string {
+	// ASCII is enough to find tag names, and keeps the offsets
+	lower := asciiLower(value)
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		if value[i] != '<' {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+		i = skipMarkup(lower, i)
+	}
+	return b.String()
+}
+
+// skipMarkup returns the offset right after the markup starting with the
+// '<' at i. Markup without its end runs to the end of the value.
+func skipMarkup(lower string, i int) int {
+	rest := lower[i:]
+	if strings.HasPrefix(rest, "<!--") {
+		return skipPast(lower, i+len("<!--"), "-->")
+	}
+	for _, name := range []string{"script", "style"} {
+		if strings.HasPrefix(rest, "<"+name) && (len(rest) == len(name)+1 || !isTagNameByte(rest[len(name)+1])) {
+			end := strings.Index(rest, "</"+name)
+			if end < 0 {
+				return len(lower)
+			}
+			return skipPast(lower, i+end, ">")
+		}
+	}
+	if len(rest) > 1 && (isTagNameByte(rest[1]) || rest[1] == '/' || rest[1] == '!' || rest[1] == '?') {
+		return skipPast(lower, i+1, ">")
+	}
+	// a stray '<'
+	return i + 1
+}
+
+func skipPast(s string, from int, sep string) int {
+	n := strings.Index(s[from:], sep)
+	if n < 0 {
+		return len(s)
+	}
+	return from + n + len(sep)
+}
+
+func isTagNameByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+	return string(b)
+}
+
+// SqueezeFilter replaces each run of the same character with a single one.
+// When 'chars' is given, only the characters in it are squeezed.
+type SqueezeFilter struct{}
+
+func (f *SqueezeFilter) Filter(value string, args *Criteria) (string, error) {
+	chars := ""
+	if args.Has("chars") {
+		s, err := args.String("chars")
+		if err != nil {
+			return "", err
+		}
+		chars = s
+	}
+	runes := make([]rune, 0, len(value))
+	for _, r := range value {
+		if len(runes) > 0 && runes[len(runes)-1] == r && (chars == "" || strings.ContainsRune(chars, r)) {
+			continue
+		}
+		runes = append(runes, r)
+	}
+	return string(runes), nil
+}
+
+// TrimUnicodeSpace removes leading and trailing white space, including the
+// ideographic space (U+3000), and invisible characters that users copy
+// along with text such as zero width space and byte order mark.
+func TrimUnicodeSpace(value string) string {
+	return strings.TrimFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\u200B' || r == '\u2060' || r == '\uFEFF'
+	})
+}
+
+func isASCIIAlnum(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+}
+
+// HalfWidthAlnum converts full-width digits and latin letters into ASCII.
+func HalfWidthAlnum(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '\uFF01' && r <= '\uFF5E' && isASCIIAlnum(r-0xFEE0) {
+			return r - 0xFEE0
+		}
+		return r
+	}, value)
+}
+
+// FullWidthAlnum converts ASCII digits and latin letters into full-width.
+func FullWidthAlnum(value string) string {
+	return strings.Map(func(r rune) rune {
+		if isASCIIAlnum(r) {
+			return r + 0xFEE0
+		}
+		return r
+	}, value)
+}
+
+// HalfWidthASCII converts every full-width form of a printable ASCII
+// character, and the ideographic space, into ASCII.
+func HalfWidthASCII(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '\uFF01' && r <= '\uFF5E' {
+			return r - 0xFEE0
+		}
+		if r == '\u3000' {
+			return ' '
+		}
+		return r
+	}, value)
+}
+
+// FullWidthASCII converts printable ASCII characters into full-width.
+func FullWidthASCII(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '!' && r <= '~' {
+			return r + 0xFEE0
+		}
+		if r == ' ' {
+			return '\u3000'
+		}
+		return r
+	}, value)
+}
+
+// halfWidthKatakana maps U+FF61..U+FF9F to their full-width forms.
+var halfWidthKatakana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゛゜")
+
+// FullWidthKatakana converts half-width katakana into full-width. A voiced
+// or semi-voiced sound mark following a kana is composed into it, so that
+// "ｶﾞ" becomes "ガ" rather than "カ゛".
+func FullWidthKatakana(value string) string {
+	runes := make([]rune, 0, len(value))
+	for _, r := range value {
+		if r < '\uFF61' || r > '\uFF9F' {
+			runes = append(runes, r)
+			continue
+		}
+		if (r == '\uFF9E' || r == '\uFF9F') && len(runes) > 0 {
+			mark := '\u3099'
+			if r == '\uFF9F' {
+				mark = '\u309A'
+			}
+			composed := []rune(norm.NFC.String(string([]rune{runes[len(runes)-1], mark})))
+			if len(composed) == 1 {
+				runes[len(runes)-1] = composed[0]
+				continue
+			}
+		}
+		runes = append(runes, halfWidthKatakana[r-'\uFF61'])
+	}
+	return string(runes)
+}
+
+// HiraganaToKatakana converts hiragana into full-width katakana.
+func HiraganaToKatakana(value string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '\u3041' && r <= '\u3096') || r == '\u309D' || r == '\u309E' {
+			return r + 0x60
+		}
+		return r
+	}, value)
+}
+
+// KatakanaToHiragana converts full-width katakana into hiragana. Katakana
+// without a hiragana counterpart, such as "ヷ", are left as they are.
+func KatakanaToHiragana(value string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '\u30A1' && r <= '\u30F6') || r == '\u30FD' || r == '\u30FE' {
+			return r - 0x60
+		}
+		return r
+	}, value)
 }
@@ -0,0 +1,120 @@
+package goformkeeper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJapaneseFilters(t *testing.T) {
+
+	if v := TrimUnicodeSpace("\u3000\u200B foo bar\u3000\uFEFF"); v != "foo bar" {
+		t.Errorf("TrimUnicodeSpace returns wrong string: %s", v)
+	}
+
+	if v := HalfWidthAlnum("０９０－１２３４ＡＢｃ"); v != "090－1234ABc" {
+		t.Errorf("HalfWidthAlnum returns wrong string: %s", v)
+	}
+
+	if v := FullWidthAlnum("090-1234"); v != "０９０-１２３４" {
+		t.Errorf("FullWidthAlnum returns wrong string: %s", v)
+	}
+
+	if v := HalfWidthASCII("０９０－１２３４　ＡＢ"); v != "090-1234 AB" {
+		t.Errorf("HalfWidthASCII returns wrong string: %s", v)
+	}
+
+	if v := FullWidthASCII("A-1 b"); v != "Ａ－１　ｂ" {
+		t.Errorf("FullWidthASCII returns wrong string: %s", v)
+	}
+
+	if v := FullWidthKatakana("ｶﾞｷﾞﾊﾟｳﾞｧｰ｡ﾞ"); v != "ガギパヴァー。゛" {
+		t.Errorf("FullWidthKatakana returns wrong string: %s", v)
+	}
+
+	if v := HiraganaToKatakana("やまだ　ゞたろう"); v != "ヤマダ　ヾタロウ" {
+		t.Errorf("HiraganaToKatakana returns wrong string: %s", v)
+	}
+
+	if v := KatakanaToHiragana("ヤマダヷー"); v != "やまだヷー" {
+		t.Errorf("KatakanaToHiragana returns wrong string: %s", v)
+	}
+}
+
+func TestNormalizationFilters(t *testing.T) {
+
+	value, err := defaultKeeper.filter(&Field{Filters: []*FilterSpec{{Name: "nfkc"}}}, "ｶﾞ１２ＡＢ㍻")
+	if err != nil {
+		t.Errorf("filter returns error: %s", err.Error())
+	}
+	if value != "ガ12AB平成" {
+		t.Errorf("nfkc returns wrong string: %s", value)
+	}
+
+	value, err = defaultKeeper.filter(&Field{Filters: []*FilterSpec{{Name: "nfc"}}}, "\u304B\u3099")
+	if err != nil {
+		t.Errorf("filter returns error: %s", err.Error())
+	}
+	if value != "が" {
+		t.Errorf("nfc returns wrong string: %s", value)
+	}
+}
+
+func TestSanitizingFilters(t *testing.T) {
+
+	collapse := CollapseSpaceFilter{}
+
+	if v, _ := collapse.Filter("foo \t　bar\n\n baz", &Criteria{}); v != "foo bar\n\n baz" {
+		t.Errorf("collapse_space returns wrong string: %q", v)
+	}
+
+	if v, _ := collapse.Filter("foo \t　bar\n\n baz", &Criteria{map[string]interface{}{"newlines": true}}); v != "foo bar baz" {
+		t.Errorf("collapse_space with newlines returns wrong string: %q", v)
+	}
+
+	newlines := NormalizeNewlinesFilter{}
+
+	if v, _ := newlines.Filter("a\r\nb\rc\nd\u2028e", &Criteria{}); v != "a\nb\nc\nd\ne" {
+		t.Errorf("normalize_newlines returns wrong string: %q", v)
+	}
+
+	if v, _ := newlines.Filter("a\nb", &Criteria{map[string]interface{}{"with": "\r\n"}}); v != "a\r\nb" {
+		t.Errorf("normalize_newlines with 'with' returns wrong string: %q", v)
+	}
+
+	if v := StripControl("a\x00b\u200Bc\u202Ed\uFEFF\te\n"); v != "abcd\te\n" {
+		t.Errorf("StripControl returns wrong string: %q", v)
+	}
+
+	if v := StripTags("<p class=\"x\">Hello<br/> <b>World</b></p><!-- note --><script>alert(1)</script> 1 < 2 &amp; 3"); v != "Hello World 1  2 &amp; 3" {
+		t.Errorf("StripTags returns wrong string: %q", v)
+	}
+
+	if v := StripTags("<<a>img src=x onerror=alert(1)>"); v != "img src=x onerror=alert(1)>" {
+		t.Errorf("StripTags leaves a tag made of the text around a removed one: %q", v)
+	}
+
+	if v := StripTags("<scr<script></script>ipt>alert(1)</SCRIPT><style>b{}</style>x<!-- a"); v != "ipt>alert(1)x" {
+		t.Errorf("StripTags leaves a script tag made of the text around a removed one: %q", v)
+	}
+
+	// a client can send any value, so it mustn't take more than linear time
+	n := 100000
+	start := time.Now()
+	if v := StripTags(strings.Repeat("<", n) + "a>" + strings.Repeat("b>", n)); v != strings.Repeat("b>", n) {
+		t.Errorf("StripTags returns wrong string for nested '<'")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("StripTags takes too long: %s", elapsed)
+	}
+
+	squeeze := SqueezeFilter{}
+
+	if v, _ := squeeze.Filter("aaa--bbb", &Criteria{}); v != "a-b" {
+		t.Errorf("squeeze returns wrong string: %q", v)
+	}
+
+	if v, _ := squeeze.Filter("aaa--bbb", &Criteria{map[string]interface{}{"chars": "-"}}); v != "aaa-bbb" {
+		t.Errorf("squeeze with chars returns wrong string: %q", v)
+	}
+}
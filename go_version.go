@@ -1,7 +1,8 @@
-// +build !go1.1
+//go:build !go1.19
+// +build !go1.19
 
 package goformkeeper
 
-func FormKeeperDoesNotSupportGo1Point0() {
-	"FormKeeper requires Go 1.2 or greater."
+func FormKeeperDoesNotSupportGoBefore1Point19() {
+	"FormKeeper requires Go 1.19 or greater."
 }
@@ -0,0 +1,160 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+// HTMLAttrs returns the HTML5 validation attributes for the input of the
+// field, so that a template renders inputs matching the rule.
+//
+//	<input name="email" {{ formAttrs "signup" "email" }}>
+//
+// renders, for example, required type="email" maxlength="20". The
+// attributes come from the rule as follows.
+//
+//	required             required
+//	rune_count, length   minlength, maxlength
+//	regex, alnum, ...    pattern
+//	email, loose_email   type="email"
+//	url                  type="url"
+//	int, number          type="number", min, max, step
+//
+// 'length' is used only when the field accepts ASCII characters alone, as
+// browsers don't count bytes. 'regex' is used only when it's anchored with
+// ^ and $, since the pattern attribute has to match the whole value.
+// Selections and unknown fields get no attributes.
+func (rule *Rule) HTMLAttrs(formName, fieldName string) template.HTMLAttr {
+	form, found := rule.Forms[formName]
+	if !found {
+		return ""
+	}
+	for _, field := range form.Fields {
+		if field.Name == fieldName {
+			return field.htmlAttrs()
+		}
+	}
+	return ""
+}
+
+// TemplateFuncs returns functions to use the rule from html/template.
+//
+//	formAttrs  HTMLAttrs of the field: {{ formAttrs "signup" "email" }}
+func (rule *Rule) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"formAttrs": rule.HTMLAttrs,
+	}
+}
+
+type htmlAttrBuilder struct {
+	names  []string
+	values map[string]string
+}
+
+func (b *htmlAttrBuilder) set(name, value string) {
+	if _, found := b.values[name]; !found {
+		b.names = append(b.names, name)
+	}
+	b.values[name] = value
+}
+
+// setOnce keeps the value set first, for attributes such as pattern which
+// can't be given twice.
+func (b *htmlAttrBuilder) setOnce(name, value string) {
+	if _, found := b.values[name]; !found {
+		b.set(name, value)
+	}
+}
+
+// setLength sets minlength or maxlength, keeping the stricter one when
+// several constraints give it.
+func (b *htmlAttrBuilder) setLength(name string, n int) {
+	if current, found := b.values[name]; found {
+		var c int
+		fmt.Sscan(current, &c)
+		if (name == "maxlength" && c <= n) || (name == "minlength" && c >= n) {
+			return
+		}
+	}
+	b.set(name, fmt.Sprint(n))
+}
+
+func (b *htmlAttrBuilder) String() string {
+	attrs := make([]string, 0, len(b.names))
+	for _, name := range b.names {
+		value := b.values[name]
+		if value == "" {
+			attrs = append(attrs, name)
+		} else {
+			attrs = append(attrs, fmt.Sprintf("%s=\"%s\"", name, html.EscapeString(value)))
+		}
+	}
+	return strings.Join(attrs, " ")
+}
+
+func (field *Field) htmlAttrs() template.HTMLAttr {
+	b := &htmlAttrBuilder{values: make(map[string]string)}
+	if field.Required {
+		b.set("required", "")
+	}
+	asciiOnly := isASCIIOnly(field.Constraints)
+	for _, constraint := range field.Constraints {
+		criteria := &Criteria{constraint.Criteria}
+		switch constraint.Type {
+		case "length":
+			if asciiOnly {
+				addLengthHTMLAttrs(b, criteria)
+			}
+		case "rune_count":
+			addLengthHTMLAttrs(b, criteria)
+		case "regex":
+			regex, err := criteria.String("regex")
+			if err == nil && strings.HasPrefix(regex, "^") && strings.HasSuffix(regex, "$") {
+				b.setOnce("pattern", regex)
+			}
+		case "alphabet", "alnum", "ascii", "ascii_without_space":
+			b.setOnce("pattern", patternConstraints[constraint.Type])
+		case "email", "loose_email":
+			b.setOnce("type", "email")
+		case "url":
+			b.setOnce("type", "url")
+		case "int", "number":
+			b.setOnce("type", "number")
+			for _, key := range []string{"from", "to", "step"} {
+				if !criteria.Has(key) {
+					continue
+				}
+				n, err := criteria.Float(key)
+				if err != nil {
+					continue
+				}
+				name := map[string]string{"from": "min", "to": "max", "step": "step"}[key]
+				b.set(name, fmt.Sprint(n))
+			}
+			if constraint.Type == "number" && !criteria.Has("step") {
+				b.set("step", "any")
+			}
+		}
+	}
+	return template.HTMLAttr(b.String())
+}
+
+func addLengthHTMLAttrs(b *htmlAttrBuilder, criteria *Criteria) {
+	if criteria.Has("eq") {
+		if eq, err := criteria.Int("eq"); err == nil {
+			b.setLength("minlength", eq)
+			b.setLength("maxlength", eq)
+		}
+	} else if criteria.Has("from") && criteria.Has("to") {
+		from, err1 := criteria.Int("from")
+		to, err2 := criteria.Int("to")
+		if err1 == nil && err2 == nil {
+			if from > 0 {
+				b.setLength("minlength", from)
+			}
+			b.setLength("maxlength", to)
+		}
+	}
+}
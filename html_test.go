@@ -0,0 +1,48 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTMLAttrs(t *testing.T) {
+	path := "./tests/html.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	cases := map[string]template.HTMLAttr{
+		"email":    `required type="email" maxlength="20"`,
+		"username": `required pattern="^[0-9a-zA-Z]+$" minlength="3" maxlength="8"`,
+		"zip":      `pattern="^[0-9]{3}-[0-9]{4}$"`,
+		"age":      `type="number" min="0" max="150"`,
+		"weight":   `type="number" min="0.5" step="any"`,
+		"note":     ``,
+		"hobby":    ``,
+		"unknown":  ``,
+	}
+	for name, want := range cases {
+		if got := rule.HTMLAttrs("signup", name); got != want {
+			t.Errorf("HTMLAttrs for %s: want %s, got %s", name, want, got)
+		}
+	}
+
+	tpl := template.Must(template.New("form").Funcs(rule.TemplateFuncs()).Parse(`<input name="email" {{ formAttrs "signup" "email" }}>`))
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, nil)
+	if err != nil {
+		t.Errorf("Failed to execute template %s", err.Error())
+		return
+	}
+	if buf.String() != `<input name="email" required type="email" maxlength="20">` {
+		t.Errorf("template renders wrong input: %s", buf.String())
+	}
+}
@@ -0,0 +1,1026 @@
+/*
+ * goformkeeper.js - validates forms in the browser with the rule bundles
+ * exported by Rule.ClientBundle.
+ *
+ *   var form = new GoFormKeeper.Form(bundle);
+ *   var result = form.validate(document.getElementById("signup"));
+ *   if (result.hasFailure()) {
+ *     result.messages().forEach(function (message) { ... });
+ *   }
+ *
+ * The built-in validators and filters behave as the ones of the server,
+ * except that 'email' and 'url' are approximated with regular expressions
+ * and 'regex' is run by the JavaScript engine. 'expr' and 'jisx0208' are
+ * not implemented. Constraints and filters without an implementation,
+ * including custom ones not added with GoFormKeeper.addValidator or
+ * GoFormKeeper.addFilter, are skipped, so the server has to validate the
+ * form again.
+ */
+(function (root, factory) {
+  if (typeof module === "object" && module.exports) {
+    module.exports = factory();
+  } else {
+    root.GoFormKeeper = factory();
+  }
+})(this, function () {
+  "use strict";
+
+  // East Asian Width of golang.org/x/text/width, as pairs of the first and
+  // the last code points of each range. client_test.go checks them.
+  var WIDE = [
+    0x1100,0x115F, 0x231A,0x231B, 0x2329,0x232A, 0x23E9,0x23EC, 0x23F0,0x23F0,
+    0x23F3,0x23F3, 0x25FD,0x25FE, 0x2614,0x2615, 0x2630,0x2637, 0x2648,0x2653,
+    0x267F,0x267F, 0x268A,0x268F, 0x2693,0x2693, 0x26A1,0x26A1, 0x26AA,0x26AB,
+    0x26BD,0x26BE, 0x26C4,0x26C5, 0x26CE,0x26CE, 0x26D4,0x26D4, 0x26EA,0x26EA,
+    0x26F2,0x26F3, 0x26F5,0x26F5, 0x26FA,0x26FA, 0x26FD,0x26FD, 0x2705,0x2705,
+    0x270A,0x270B, 0x2728,0x2728, 0x274C,0x274C, 0x274E,0x274E, 0x2753,0x2755,
+    0x2757,0x2757, 0x2795,0x2797, 0x27B0,0x27B0, 0x27BF,0x27BF, 0x2B1B,0x2B1C,
+    0x2B50,0x2B50, 0x2B55,0x2B55, 0x2E80,0x2E99, 0x2E9B,0x2EF3, 0x2F00,0x2FD5,
+    0x2FF0,0x303E, 0x3041,0x3096, 0x3099,0x30FF, 0x3105,0x312F, 0x3131,0x318E,
+    0x3190,0x31E5, 0x31EF,0x321E, 0x3220,0x3247, 0x3250,0xA48C, 0xA490,0xA4C6,
+    0xA960,0xA97C, 0xAC00,0xD7A3, 0xF900,0xFAFF, 0xFE10,0xFE19, 0xFE30,0xFE52,
+    0xFE54,0xFE66, 0xFE68,0xFE6B, 0xFF01,0xFF60, 0xFFE0,0xFFE6,
+    0x16FE0,0x16FE4, 0x16FF0,0x16FF6, 0x17000,0x18CD5, 0x18CFF,0x18D1E,
+    0x18D80,0x18DF2, 0x1AFF0,0x1AFF3, 0x1AFF5,0x1AFFB, 0x1AFFD,0x1AFFE,
+    0x1B000,0x1B122, 0x1B132,0x1B132, 0x1B150,0x1B152, 0x1B155,0x1B155,
+    0x1B164,0x1B167, 0x1B170,0x1B2FB, 0x1D300,0x1D356, 0x1D360,0x1D376,
+    0x1F004,0x1F004, 0x1F0CF,0x1F0CF, 0x1F18E,0x1F18E, 0x1F191,0x1F19A,
+    0x1F200,0x1F202, 0x1F210,0x1F23B, 0x1F240,0x1F248, 0x1F250,0x1F251,
+    0x1F260,0x1F265, 0x1F300,0x1F320, 0x1F32D,0x1F335, 0x1F337,0x1F37C,
+    0x1F37E,0x1F393, 0x1F3A0,0x1F3CA, 0x1F3CF,0x1F3D3, 0x1F3E0,0x1F3F0,
+    0x1F3F4,0x1F3F4, 0x1F3F8,0x1F43E, 0x1F440,0x1F440, 0x1F442,0x1F4FC,
+    0x1F4FF,0x1F53D, 0x1F54B,0x1F54E, 0x1F550,0x1F567, 0x1F57A,0x1F57A,
+    0x1F595,0x1F596, 0x1F5A4,0x1F5A4, 0x1F5FB,0x1F64F, 0x1F680,0x1F6C5,
+    0x1F6CC,0x1F6CC, 0x1F6D0,0x1F6D2, 0x1F6D5,0x1F6D8, 0x1F6DC,0x1F6DF,
+    0x1F6EB,0x1F6EC, 0x1F6F4,0x1F6FC, 0x1F7E0,0x1F7EB, 0x1F7F0,0x1F7F0,
+    0x1F90C,0x1F93A, 0x1F93C,0x1F945, 0x1F947,0x1F9FF, 0x1FA70,0x1FA7C,
+    0x1FA80,0x1FA8A, 0x1FA8E,0x1FAC6, 0x1FAC8,0x1FAC8, 0x1FACD,0x1FADC,
+    0x1FADF,0x1FAEA, 0x1FAEF,0x1FAF8, 0x20000,0x3FFFF
+  ];
+  var AMBIGUOUS = [
+    0xA1,0xA1, 0xA4,0xA4, 0xA7,0xA8, 0xAA,0xAA, 0xAD,0xAE, 0xB0,0xB4,
+    0xB6,0xBA, 0xBC,0xBF, 0xC6,0xC6, 0xD0,0xD0, 0xD7,0xD8, 0xDE,0xE1,
+    0xE6,0xE6, 0xE8,0xEA, 0xEC,0xED, 0xF0,0xF0, 0xF2,0xF3, 0xF7,0xFA,
+    0xFC,0xFC, 0xFE,0xFE, 0x101,0x101, 0x111,0x111, 0x113,0x113, 0x11B,0x11B,
+    0x126,0x127, 0x12B,0x12B, 0x131,0x133, 0x138,0x138, 0x13F,0x142,
+    0x144,0x144, 0x148,0x14B, 0x14D,0x14D, 0x152,0x153, 0x166,0x167,
+    0x16B,0x16B, 0x1CE,0x1CE, 0x1D0,0x1D0, 0x1D2,0x1D2, 0x1D4,0x1D4,
+    0x1D6,0x1D6, 0x1D8,0x1D8, 0x1DA,0x1DA, 0x1DC,0x1DC, 0x251,0x251,
+    0x261,0x261, 0x2C4,0x2C4, 0x2C7,0x2C7, 0x2C9,0x2CB, 0x2CD,0x2CD,
+    0x2D0,0x2D0, 0x2D8,0x2DB, 0x2DD,0x2DD, 0x2DF,0x2DF, 0x300,0x36F,
+    0x391,0x3A1, 0x3A3,0x3A9, 0x3B1,0x3C1, 0x3C3,0x3C9, 0x401,0x401,
+    0x410,0x44F, 0x451,0x451, 0x2010,0x2010, 0x2013,0x2016, 0x2018,0x2019,
+    0x201C,0x201D, 0x2020,0x2022, 0x2024,0x2027, 0x2030,0x2030, 0x2032,0x2033,
+    0x2035,0x2035, 0x203B,0x203B, 0x203E,0x203E, 0x2074,0x2074, 0x207F,0x207F,
+    0x2081,0x2084, 0x20AC,0x20AC, 0x2103,0x2103, 0x2105,0x2105, 0x2109,0x2109,
+    0x2113,0x2113, 0x2116,0x2116, 0x2121,0x2122, 0x2126,0x2126, 0x212B,0x212B,
+    0x2153,0x2154, 0x215B,0x215E, 0x2160,0x216B, 0x2170,0x2179, 0x2189,0x2189,
+    0x2190,0x2199, 0x21B8,0x21B9, 0x21D2,0x21D2, 0x21D4,0x21D4, 0x21E7,0x21E7,
+    0x2200,0x2200, 0x2202,0x2203, 0x2207,0x2208, 0x220B,0x220B, 0x220F,0x220F,
+    0x2211,0x2211, 0x2215,0x2215, 0x221A,0x221A, 0x221D,0x2220, 0x2223,0x2223,
+    0x2225,0x2225, 0x2227,0x222C, 0x222E,0x222E, 0x2234,0x2237, 0x223C,0x223D,
+    0x2248,0x2248, 0x224C,0x224C, 0x2252,0x2252, 0x2260,0x2261, 0x2264,0x2267,
+    0x226A,0x226B, 0x226E,0x226F, 0x2282,0x2283, 0x2286,0x2287, 0x2295,0x2295,
+    0x2299,0x2299, 0x22A5,0x22A5, 0x22BF,0x22BF, 0x2312,0x2312, 0x2460,0x24E9,
+    0x24EB,0x254B, 0x2550,0x2573, 0x2580,0x258F, 0x2592,0x2595, 0x25A0,0x25A1,
+    0x25A3,0x25A9, 0x25B2,0x25B3, 0x25B6,0x25B7, 0x25BC,0x25BD, 0x25C0,0x25C1,
+    0x25C6,0x25C8, 0x25CB,0x25CB, 0x25CE,0x25D1, 0x25E2,0x25E5, 0x25EF,0x25EF,
+    0x2605,0x2606, 0x2609,0x2609, 0x260E,0x260F, 0x261C,0x261C, 0x261E,0x261E,
+    0x2640,0x2640, 0x2642,0x2642, 0x2660,0x2661, 0x2663,0x2665, 0x2667,0x266A,
+    0x266C,0x266D, 0x266F,0x266F, 0x269E,0x269F, 0x26BF,0x26BF, 0x26C6,0x26CD,
+    0x26CF,0x26D3, 0x26D5,0x26E1, 0x26E3,0x26E3, 0x26E8,0x26E9, 0x26EB,0x26F1,
+    0x26F4,0x26F4, 0x26F6,0x26F9, 0x26FB,0x26FC, 0x26FE,0x26FF, 0x273D,0x273D,
+    0x2776,0x277F, 0x2B56,0x2B59, 0x3248,0x324F, 0xD800,0xF8FF, 0xFE00,0xFE0F,
+    0xFFFD,0xFFFD, 0x1F100,0x1F10A, 0x1F110,0x1F12D, 0x1F130,0x1F169,
+    0x1F170,0x1F18D, 0x1F18F,0x1F190, 0x1F19B,0x1F1AC, 0xE0100,0xE01EF,
+    0xF0000,0xFFFFD, 0x100000,0x10FFFD
+  ];
+
+  function inRanges(ranges, c) {
+    var lo = 0, hi = ranges.length / 2 - 1;
+    while (lo <= hi) {
+      var mid = (lo + hi) >> 1;
+      if (c < ranges[mid * 2]) {
+        hi = mid - 1;
+      } else if (c > ranges[mid * 2 + 1]) {
+        lo = mid + 1;
+      } else {
+        return true;
+      }
+    }
+    return false;
+  }
+
+  function isWide(c, ambiguousWide) {
+    return inRanges(WIDE, c) || (ambiguousWide && inRanges(AMBIGUOUS, c));
+  }
+
+  // the characters of the string as code points
+  function codePoints(value) {
+    var points = [];
+    for (var i = 0; i < value.length; i++) {
+      var c = value.charCodeAt(i);
+      if (c >= 0xD800 && c <= 0xDBFF && i + 1 < value.length) {
+        var d = value.charCodeAt(i + 1);
+        if (d >= 0xDC00 && d <= 0xDFFF) {
+          points.push((c - 0xD800) * 0x400 + (d - 0xDC00) + 0x10000);
+          i++;
+          continue;
+        }
+      }
+      points.push(c);
+    }
+    return points;
+  }
+
+  function fromCodePoints(points) {
+    var s = "";
+    for (var i = 0; i < points.length; i++) {
+      var c = points[i];
+      if (c >= 0x10000) {
+        c -= 0x10000;
+        s += String.fromCharCode(0xD800 + (c >> 10), 0xDC00 + (c & 0x3FF));
+      } else {
+        s += String.fromCharCode(c);
+      }
+    }
+    return s;
+  }
+
+  function mapCodePoints(value, f) {
+    return fromCodePoints(codePoints(value).map(f));
+  }
+
+  function utf8Length(c) {
+    if (c < 0x80) {
+      return 1;
+    } else if (c < 0x800) {
+      return 2;
+    } else if (c < 0x10000) {
+      return 3;
+    }
+    return 4;
+  }
+
+  function byteLength(value) {
+    var n = 0;
+    codePoints(value).forEach(function (c) { n += utf8Length(c); });
+    return n;
+  }
+
+  function displayWidth(value, ambiguousWide) {
+    var n = 0;
+    codePoints(value).forEach(function (c) { n += isWide(c, ambiguousWide) ? 2 : 1; });
+    return n;
+  }
+
+  // white space as unicode.IsSpace of Go sees it
+  var SPACE = "\\t\\n\\v\\f\\r \\u0085\\u00A0\\u1680\\u2000-\\u200A\\u2028\\u2029\\u202F\\u205F\\u3000";
+
+  var SPACE_PATTERN = new RegExp("^[" + SPACE + "]$");
+
+  function isSpace(c) {
+    return SPACE_PATTERN.test(String.fromCharCode(c));
+  }
+
+  function isNewline(c) {
+    return c === 0x0A || c === 0x0D || c === 0x85 || c === 0x2028 || c === 0x2029;
+  }
+
+  var POSIX_CLASSES = {
+    alnum: "0-9A-Za-z", alpha: "A-Za-z", ascii: "\\x00-\\x7F", blank: "\\t ",
+    cntrl: "\\x00-\\x1F\\x7F", digit: "0-9", graph: "!-~", lower: "a-z",
+    print: " -~", punct: "!-\\/:-@\\[-`{-~", space: "\\t\\n\\v\\f\\r ",
+    upper: "A-Z", word: "0-9A-Za-z_", xdigit: "0-9A-Fa-f"
+  };
+
+  // goRegExp translates a regular expression of Go into a RegExp, or
+  // returns null when the engine can't run it.
+  function goRegExp(pattern, global) {
+    var flags = global ? "g" : "";
+    var m = /^\(\?([imsU]+)\)/.exec(pattern);
+    if (m) {
+      if (m[1].indexOf("U") >= 0) {
+        return null;
+      }
+      flags += m[1];
+      pattern = pattern.substring(m[0].length);
+    }
+    pattern = pattern
+      .replace(/\(\?P</g, "(?<")
+      .replace(/\\x\{([0-9A-Fa-f]+)\}/g, "\\u{$1}")
+      .replace(/\\p([A-Z])/g, "\\p{$1}")
+      .replace(/\\P([A-Z])/g, "\\P{$1}")
+      .replace(/\\A/g, "^")
+      .replace(/\\z/g, "$")
+      .replace(/\[:(\^?)([a-z]+):\]/g, function (all, negate, name) {
+        if (negate || !POSIX_CLASSES[name]) {
+          throw new Error("unsupported class " + all);
+        }
+        return POSIX_CLASSES[name];
+      });
+    try {
+      return new RegExp(pattern, flags + "u");
+    } catch (e) {
+      try {
+        return new RegExp(pattern, flags);
+      } catch (e2) {
+        return null;
+      }
+    }
+  }
+
+  function compileGoRegExp(pattern, global) {
+    try {
+      return goRegExp(pattern, global);
+    } catch (e) {
+      return null;
+    }
+  }
+
+  // rangeCheck checks n against 'eq', or 'from' and 'to', as the length
+  // like constraints do. It returns null when the criteria aren't enough.
+  function rangeCheck(n, criteria) {
+    if (typeof criteria.eq === "number") {
+      return n === criteria.eq;
+    } else if (typeof criteria.from === "number" && typeof criteria.to === "number") {
+      return n >= criteria.from && n <= criteria.to;
+    }
+    return null;
+  }
+
+  function isSpaceAllowed(c, criteria) {
+    return criteria.allow_space === true && (c === 0x20 || c === 0x3000);
+  }
+
+  function everyCodePoint(value, f) {
+    return value !== "" && codePoints(value).every(f);
+  }
+
+  var INT64_MAX = "9223372036854775807";
+
+  function isInt64(value) {
+    var m = /^([+-]?)0*([0-9]+)$/.exec(value);
+    if (!m) {
+      return false;
+    }
+    var limit = m[1] === "-" ? "9223372036854775808" : INT64_MAX;
+    return m[2].length < limit.length || (m[2].length === limit.length && m[2] <= limit);
+  }
+
+  function numberCheck(n, criteria, integer) {
+    var base = 0;
+    if (typeof criteria.from === "number") {
+      if (n < criteria.from) {
+        return false;
+      }
+      base = criteria.from;
+    }
+    if (typeof criteria.to === "number" && n > criteria.to) {
+      return false;
+    }
+    if (typeof criteria.step === "number" && criteria.step > 0) {
+      if (integer) {
+        return (n - base) % criteria.step === 0;
+      }
+      var q = (n - base) / criteria.step;
+      return Math.abs(q - Math.round(q)) <= 1e-9;
+    }
+    return true;
+  }
+
+  var ATOM = "[A-Za-z0-9!#$%&'*+\\-/=?^_`{|}~.\\u0080-\\uFFFF]+";
+  var EMAIL = new RegExp("^(?:" + ATOM + "|\"(?:[^\"\\\\]|\\\\.)*\")@(?:" + ATOM + "|\\[[^\\[\\]\\\\]*\\])$");
+
+  // A validator returns true when the value passes, false when it doesn't,
+  // or null when it can't tell, for example for lack of criteria.
+  var validators = {
+    length: function (value, criteria) {
+      return rangeCheck(byteLength(value), criteria);
+    },
+    rune_count: function (value, criteria) {
+      return rangeCheck(codePoints(value).length, criteria);
+    },
+    display_width: function (value, criteria) {
+      return rangeCheck(displayWidth(value, criteria.ambiguous_wide === true), criteria);
+    },
+    alphabet: function (value) {
+      return /^[a-zA-Z]+$/.test(value);
+    },
+    alnum: function (value) {
+      return /^[0-9a-zA-Z]+$/.test(value);
+    },
+    ascii: function (value) {
+      return /^[\x20-\x7E]+$/.test(value);
+    },
+    ascii_without_space: function (value) {
+      return /^[\x21-\x7E]+$/.test(value);
+    },
+    hiragana: function (value, criteria) {
+      return everyCodePoint(value, function (c) {
+        return (c >= 0x3041 && c <= 0x3096) || (c >= 0x309D && c <= 0x309F) ||
+          c === 0x30FC || isSpaceAllowed(c, criteria);
+      });
+    },
+    katakana: function (value, criteria) {
+      return everyCodePoint(value, function (c) {
+        return (c >= 0x30A1 && c <= 0x30FF) || (c >= 0x31F0 && c <= 0x31FF) ||
+          isSpaceAllowed(c, criteria);
+      });
+    },
+    full_width: function (value, criteria) {
+      var ambiguousWide = criteria.ambiguous_wide !== false;
+      return everyCodePoint(value, function (c) { return isWide(c, ambiguousWide); });
+    },
+    half_width: function (value) {
+      return everyCodePoint(value, function (c) { return !isWide(c, true); });
+    },
+    regex: function (value, criteria) {
+      if (typeof criteria.regex !== "string") {
+        return null;
+      }
+      var re = compileGoRegExp(criteria.regex, false);
+      return re ? re.test(value) : null;
+    },
+    url: function (value) {
+      return /^(?:[A-Za-z][A-Za-z0-9+.\-]*:|\/)[^\s\x00-\x1F\x7F]*$/.test(value);
+    },
+    email: function (value) {
+      var m = /^[^<]*<([^<>]*)>\s*$/.exec(value);
+      return EMAIL.test(m ? m[1] : value);
+    },
+    loose_email: function (value) {
+      return /^[a-zA-Z0-9.!#$%&'*+\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$/.test(value);
+    },
+    included: function (value, criteria) {
+      if (!Array.isArray(criteria["in"])) {
+        return null;
+      }
+      return criteria["in"].indexOf(value) >= 0;
+    },
+    "int": function (value, criteria) {
+      if (!isInt64(value)) {
+        return false;
+      }
+      return numberCheck(parseInt(value, 10), criteria, true);
+    },
+    number: function (value, criteria) {
+      if (!/^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$/.test(value)) {
+        return false;
+      }
+      var n = parseFloat(value);
+      if (!isFinite(n)) {
+        return false;
+      }
+      return numberCheck(n, criteria, false);
+    }
+  };
+
+  var HALF_WIDTH_KATAKANA = codePoints("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゛゜");
+
+  function isASCIIAlnum(c) {
+    return (c >= 0x30 && c <= 0x39) || (c >= 0x41 && c <= 0x5A) || (c >= 0x61 && c <= 0x7A);
+  }
+
+  function trimSpace(value, extra) {
+    var chars = "[" + SPACE + (extra || "") + "]+";
+    return value.replace(new RegExp("^" + chars), "").replace(new RegExp(chars + "$"), "");
+  }
+
+  function normalize(form) {
+    return function (value) {
+      return value.normalize ? value.normalize(form) : value;
+    };
+  }
+
+  // A filter returns the converted value. To reject the value, it throws
+  // a FilterFailure.
+  var filters = {
+    trim: function (value) {
+      return trimSpace(value);
+    },
+    lowercase: function (value) {
+      return value.toLowerCase();
+    },
+    uppercase: function (value) {
+      return value.toUpperCase();
+    },
+    trim_unicode: function (value) {
+      return trimSpace(value, "\\u200B\\u2060\\uFEFF");
+    },
+    half_width_alnum: function (value) {
+      return mapCodePoints(value, function (c) {
+        return c >= 0xFF01 && c <= 0xFF5E && isASCIIAlnum(c - 0xFEE0) ? c - 0xFEE0 : c;
+      });
+    },
+    full_width_alnum: function (value) {
+      return mapCodePoints(value, function (c) {
+        return isASCIIAlnum(c) ? c + 0xFEE0 : c;
+      });
+    },
+    half_width_ascii: function (value) {
+      return mapCodePoints(value, function (c) {
+        if (c >= 0xFF01 && c <= 0xFF5E) {
+          return c - 0xFEE0;
+        }
+        return c === 0x3000 ? 0x20 : c;
+      });
+    },
+    full_width_ascii: function (value) {
+      return mapCodePoints(value, function (c) {
+        if (c >= 0x21 && c <= 0x7E) {
+          return c + 0xFEE0;
+        }
+        return c === 0x20 ? 0x3000 : c;
+      });
+    },
+    full_width_katakana: function (value) {
+      var points = [];
+      codePoints(value).forEach(function (c) {
+        if (c < 0xFF61 || c > 0xFF9F) {
+          points.push(c);
+          return;
+        }
+        if ((c === 0xFF9E || c === 0xFF9F) && points.length > 0 && "".normalize) {
+          var mark = c === 0xFF9E ? 0x3099 : 0x309A;
+          var composed = codePoints(fromCodePoints([points[points.length - 1], mark]).normalize("NFC"));
+          if (composed.length === 1) {
+            points[points.length - 1] = composed[0];
+            return;
+          }
+        }
+        points.push(HALF_WIDTH_KATAKANA[c - 0xFF61]);
+      });
+      return fromCodePoints(points);
+    },
+    hiragana: function (value) {
+      return mapCodePoints(value, function (c) {
+        return (c >= 0x30A1 && c <= 0x30F6) || c === 0x30FD || c === 0x30FE ? c - 0x60 : c;
+      });
+    },
+    katakana: function (value) {
+      return mapCodePoints(value, function (c) {
+        return (c >= 0x3041 && c <= 0x3096) || c === 0x309D || c === 0x309E ? c + 0x60 : c;
+      });
+    },
+    nfc: normalize("NFC"),
+    nfkc: normalize("NFKC"),
+    truncate: function (value, args) {
+      var points = codePoints(value);
+      if (typeof args.runes === "number") {
+        return fromCodePoints(points.slice(0, args.runes));
+      } else if (typeof args.bytes === "number") {
+        var n = 0, end = 0;
+        while (end < points.length && n + utf8Length(points[end]) <= args.bytes) {
+          n += utf8Length(points[end]);
+          end++;
+        }
+        return fromCodePoints(points.slice(0, end));
+      }
+      return value;
+    },
+    replace: function (value, args) {
+      var re = typeof args.pattern === "string" ? compileGoRegExp(args.pattern, true) : null;
+      if (!re) {
+        return value;
+      }
+      var with_ = typeof args["with"] === "string" ? args["with"] : "";
+      with_ = with_.replace(/\$\{([0-9]+)\}/g, "$$$1").replace(/\$\{(\w+)\}/g, "$$<$1>");
+      return value.replace(re, with_);
+    },
+    collapse_space: function (value, args) {
+      var points = [];
+      var inSpace = false;
+      codePoints(value).forEach(function (c) {
+        if (isSpace(c) && (args.newlines === true || !isNewline(c))) {
+          if (!inSpace) {
+            points.push(0x20);
+          }
+          inSpace = true;
+          return;
+        }
+        inSpace = false;
+        points.push(c);
+      });
+      return fromCodePoints(points);
+    },
+    normalize_newlines: function (value, args) {
+      var with_ = typeof args["with"] === "string" ? args["with"] : "\n";
+      return value.replace(/\r\n|[\r\n\u0085\u2028\u2029]/g, function () { return with_; });
+    },
+    strip_control: function (value) {
+      var re = compileGoRegExp("[\\p{Cc}\\p{Cf}]", true);
+      if (!re || !re.unicode) {
+        return value;
+      }
+      return value.replace(re, function (c) {
+        return c === "\t" || c === "\n" || c === "\r" ? c : "";
+      });
+    },
+    strip_tags: function (value) {
+      // single pass as StripTags, see filters.go
+      var lower = value.replace(/[A-Z]/g, function (c) { return c.toLowerCase(); });
+      function skipPast(from, sep) {
+        var n = lower.indexOf(sep, from);
+        return n < 0 ? lower.length : n + sep.length;
+      }
+      function skipMarkup(i) {
+        if (lower.substr(i, 4) === "<!--") {
+          return skipPast(i + 4, "-->");
+        }
+        var names = ["script", "style"];
+        for (var k = 0; k < names.length; k++) {
+          var name = names[k];
+          if (lower.substr(i, name.length + 1) === "<" + name && !/[a-z]/.test(lower.charAt(i + name.length + 1))) {
+            var end = lower.indexOf("</" + name, i);
+            return end < 0 ? lower.length : skipPast(end, ">");
+          }
+        }
+        if (/[a-z\/!?]/.test(lower.charAt(i + 1))) {
+          return skipPast(i + 1, ">");
+        }
+        return i + 1;
+      }
+      var out = [];
+      var i = 0;
+      while (i < value.length) {
+        var next = value.indexOf("<", i);
+        if (next < 0) {
+          out.push(value.slice(i));
+          break;
+        }
+        out.push(value.slice(i, next));
+        i = skipMarkup(next);
+      }
+      return out.join("");
+    },
+    squeeze: function (value, args) {
+      var chars = typeof args.chars === "string" ? codePoints(args.chars) : null;
+      var points = [];
+      codePoints(value).forEach(function (c) {
+        if (points.length > 0 && points[points.length - 1] === c && (!chars || chars.indexOf(c) >= 0)) {
+          return;
+        }
+        points.push(c);
+      });
+      return fromCodePoints(points);
+    }
+  };
+
+  var COMPOSITES = { any_of: true, all_of: true, not: true, when: true };
+
+  function FilterFailure(message) {
+    this.message = message || "";
+  }
+
+  function Result() {
+    this.validFields = {};
+    this.validSelections = {};
+    this.failures = {};
+  }
+
+  Result.prototype.hasFailure = function () {
+    return this.failedFields().length > 0;
+  };
+
+  Result.prototype.failedOn = function (name) {
+    return Object.prototype.hasOwnProperty.call(this.failures, name);
+  };
+
+  Result.prototype.failedOnConstraint = function (name, constraintType) {
+    return this.failedOn(name) &&
+      Object.prototype.hasOwnProperty.call(this.failures[name].constraints, constraintType);
+  };
+
+  Result.prototype.failedFields = function () {
+    return Object.keys(this.failures).sort();
+  };
+
+  // failedItems returns the failures of each value of the selection, in
+  // the order the values were given.
+  Result.prototype.failedItems = function (name) {
+    return this.failedOn(name) && this.failures[name].items ? this.failures[name].items : [];
+  };
+
+  Result.prototype.failedOnItem = function (name, index) {
+    return this.failedItems(name).some(function (item) {
+      return item.index === index;
+    });
+  };
+
+  Result.prototype.messageOn = function (name) {
+    return this.failedOn(name) ? this.failures[name].message : "";
+  };
+
+  Result.prototype.messageOnConstraint = function (name, constraintType) {
+    if (!this.failedOn(name)) {
+      return "";
+    }
+    var failure = this.failures[name];
+    var constraint = failure.constraints[constraintType];
+    return constraint && constraint.message ? constraint.message : failure.message;
+  };
+
+  Result.prototype.messagesOn = function (name) {
+    if (!this.failedOn(name)) {
+      return [];
+    }
+    var constraints = this.failures[name].constraints;
+    return uniq(Object.keys(constraints).map(function (type) {
+      return constraints[type].message;
+    }));
+  };
+
+  Result.prototype.messages = function () {
+    var self = this;
+    return uniq(Object.keys(this.failures).map(function (name) {
+      return self.messageOn(name);
+    }));
+  };
+
+  function uniq(values) {
+    var seen = {};
+    return values.filter(function (v) {
+      if (v === "" || seen[v]) {
+        return false;
+      }
+      seen[v] = true;
+      return true;
+    }).sort();
+  }
+
+  // failureKey identifies the constraint within the field, as
+  // ConstraintFailure.Key does.
+  function failureKey(constraintFailure) {
+    if (constraintFailure.id) {
+      return constraintFailure.id;
+    }
+    if (constraintFailure.index >= 0) {
+      return String(constraintFailure.index);
+    }
+    return constraintFailure.constraintType;
+  }
+
+  function addFailure(result, name, message, constraintFailure) {
+    if (!result.failedOn(name)) {
+      result.failures[name] = { fieldName: name, message: message, constraints: {}, all: [] };
+    }
+    var failure = result.failures[name];
+    var key = failureKey(constraintFailure);
+    if (failure.all.some(function (c) { return failureKey(c) === key; })) {
+      return;
+    }
+    failure.all.push(constraintFailure);
+    if (!Object.prototype.hasOwnProperty.call(failure.constraints, constraintFailure.constraintType)) {
+      failure.constraints[constraintFailure.constraintType] = constraintFailure;
+    }
+  }
+
+  // valuesOf returns a function to pick the submitted values by name from
+  // a form element, FormData, URLSearchParams or a plain object whose
+  // values are strings or arrays of strings.
+  function valuesOf(params) {
+    if (params && typeof params.elements === "object" && typeof FormData === "function") {
+      params = new FormData(params);
+    }
+    if (params && typeof params.getAll === "function") {
+      return function (name) {
+        return params.getAll(name).filter(function (v) { return typeof v === "string"; });
+      };
+    }
+    return function (name) {
+      var v = params ? params[name] : undefined;
+      if (v === undefined || v === null) {
+        return [];
+      }
+      return Array.isArray(v) ? v.map(String) : [String(v)];
+    };
+  }
+
+  function Form(bundle) {
+    this.bundle = typeof bundle === "string" ? JSON.parse(bundle) : bundle;
+  }
+
+  // unsupported lists the constraint types and filters of the form which
+  // are neither built in nor added, and so are skipped.
+  Form.prototype.unsupported = function () {
+    var names = {};
+    function walk(constraints) {
+      (constraints || []).forEach(function (c) {
+        if (!COMPOSITES[c.type] && !validators[c.type]) {
+          names["constraint:" + c.type] = true;
+        }
+        walk(c.constraints);
+        walk(c["if"]);
+      });
+    }
+    var items = (this.bundle.fields || []).concat(this.bundle.selections || []);
+    items.forEach(function (item) {
+      (item.filters || []).forEach(function (f) {
+        if (!filters[f.name]) {
+          names["filter:" + f.name] = true;
+        }
+      });
+      walk(item.constraints);
+    });
+    return Object.keys(names).sort();
+  };
+
+  Form.prototype.validate = function (params) {
+    var values = valuesOf(params);
+    var bundle = this.bundle;
+    var result = new Result();
+    var ctx = { fields: {}, selections: {}, selectionIndexes: {}, rejected: {} };
+    var filterFailures = {};
+
+    var duplicatedFields = {};
+    (bundle.fields || []).forEach(function (field) {
+      var value = pickValue(field, values(field.name));
+      if (value === null) {
+        duplicatedFields[field.name] = true;
+        ctx.rejected[field.name] = true;
+        return;
+      }
+      if (value === "" && field["default"]) {
+        value = field["default"];
+      }
+      try {
+        ctx.fields[field.name] = applyFilters(field.filters, value);
+      } catch (e) {
+        if (!(e instanceof FilterFailure)) {
+          throw e;
+        }
+        filterFailures[field.name] = e;
+        ctx.rejected[field.name] = true;
+      }
+    });
+
+    (bundle.selections || []).forEach(function (selection) {
+      var filtered = [];
+      var indexes = [];
+      try {
+        values(selection.name).forEach(function (value, i) {
+          value = applyFilters(selection.filters, value);
+          if (value !== "") {
+            filtered.push(value);
+            indexes.push(i);
+          }
+        });
+      } catch (e) {
+        if (!(e instanceof FilterFailure)) {
+          throw e;
+        }
+        filterFailures[selection.name] = e;
+        ctx.rejected[selection.name] = true;
+      }
+      ctx.selections[selection.name] = filtered;
+      ctx.selectionIndexes[selection.name] = indexes;
+    });
+
+    (bundle.fields || []).concat(bundle.selections || []).forEach(function (item) {
+      var failure = filterFailures[item.name];
+      if (failure) {
+        addFailure(result, item.name, item.message || "", {
+          constraintType: failure.filterName,
+          message: failure.message,
+          index: -1
+        });
+      }
+    });
+
+    (bundle.fields || []).forEach(function (field) {
+      if (filterFailures[field.name]) {
+        return;
+      }
+      if (duplicatedFields[field.name]) {
+        addFailure(result, field.name, field.message || "", {
+          constraintType: "duplicate",
+          message: field.message || "",
+          index: -1
+        });
+        return;
+      }
+      var value = ctx.fields[field.name];
+      if (value === "") {
+        if (field.required) {
+          addFailure(result, field.name, field.message || "", {
+            constraintType: "required",
+            message: field.message || "",
+            index: -1
+          });
+        } else {
+          result.validFields[field.name] = "";
+        }
+        return;
+      }
+      var passAll = true;
+      var constraints = field.constraints || [];
+      for (var i = 0; i < constraints.length; i++) {
+        var constraintFailure = check(ctx, value, constraints[i]);
+        if (constraintFailure) {
+          passAll = false;
+          constraintFailure.index = i;
+          addFailure(result, field.name, field.message || "", constraintFailure);
+          if (!field.fall_through) {
+            break;
+          }
+        }
+      }
+      if (passAll) {
+        result.validFields[field.name] = value;
+      }
+    });
+
+    (bundle.selections || []).forEach(function (selection) {
+      if (filterFailures[selection.name]) {
+        return;
+      }
+      var filtered = ctx.selections[selection.name];
+      var count = selection.count || { from: 0, to: 0 };
+      if (filtered.length < count.from || filtered.length > count.to) {
+        addFailure(result, selection.name, selection.message || "", {
+          constraintType: "required",
+          message: selection.message || "",
+          index: -1
+        });
+        return;
+      }
+      var items = [];
+      filtered.forEach(function (value, n) {
+        var item = null;
+        var constraints = selection.constraints || [];
+        for (var i = 0; i < constraints.length; i++) {
+          var constraintFailure = check(ctx, value, constraints[i]);
+          if (!constraintFailure) {
+            continue;
+          }
+          constraintFailure.index = i;
+          if (!item) {
+            item = { index: ctx.selectionIndexes[selection.name][n], value: value, constraints: [] };
+          }
+          item.constraints.push(constraintFailure);
+          addFailure(result, selection.name, selection.message || "", constraintFailure);
+          if (!selection.fall_through) {
+            break;
+          }
+        }
+        if (item) {
+          items.push(item);
+        }
+      });
+      if (items.length > 0) {
+        result.failures[selection.name].items = items;
+      } else {
+        result.validSelections[selection.name] = filtered;
+      }
+    });
+
+    return result;
+  };
+
+  // pickValue picks the value of the field by its duplicates policy, or
+  // returns null when the policy rejects the values.
+  function pickValue(field, vs) {
+    if (vs.length === 0) {
+      return "";
+    }
+    switch (field.duplicates) {
+    case "last":
+      return vs[vs.length - 1];
+    case "join":
+      return vs.join(",");
+    case "reject":
+      if (vs.length > 1) {
+        return null;
+      }
+    }
+    return vs[0];
+  }
+
+  function applyFilters(specs, value) {
+    (specs || []).forEach(function (spec) {
+      var f = filters[spec.name];
+      if (!f) {
+        return;
+      }
+      try {
+        value = f(value, spec.args || {});
+      } catch (e) {
+        if (e instanceof FilterFailure) {
+          var failure = new FilterFailure(e.message || spec.message || "");
+          failure.filterName = spec.name;
+          throw failure;
+        }
+        throw e;
+      }
+    });
+    return value;
+  }
+
+  function compositeFailure(constraint, inner) {
+    var failure = leafFailure(constraint);
+    failure.inner = inner;
+    return failure;
+  }
+
+  function leafFailure(constraint) {
+    var failure = { constraintType: constraint.type, message: constraint.message || "", index: 0 };
+    if (constraint.id) {
+      failure.id = constraint.id;
+    }
+    return failure;
+  }
+
+  function checkAll(ctx, value, constraints) {
+    var failures = [];
+    (constraints || []).forEach(function (c, i) {
+      var failure = check(ctx, value, c);
+      if (failure) {
+        failure.index = i;
+        failures.push(failure);
+      }
+    });
+    return failures;
+  }
+
+  // check returns null when the value passes the constraint, or the
+  // failure describing why it didn't, in the shape of ConstraintFailure.
+  function check(ctx, value, constraint) {
+    var inner;
+    switch (constraint.type) {
+    case "any_of":
+      inner = [];
+      var constraints = constraint.constraints || [];
+      for (var i = 0; i < constraints.length; i++) {
+        var failure = check(ctx, value, constraints[i]);
+        if (!failure) {
+          return null;
+        }
+        failure.index = i;
+        inner.push(failure);
+      }
+      return inner.length > 0 ? compositeFailure(constraint, inner) : null;
+    case "all_of":
+      inner = checkAll(ctx, value, constraint.constraints);
+      return inner.length > 0 ? compositeFailure(constraint, inner) : null;
+    case "not":
+      inner = checkAll(ctx, value, constraint.constraints);
+      return inner.length === 0 && (constraint.constraints || []).length > 0 ?
+        compositeFailure(constraint, []) : null;
+    case "when":
+      if (!matchCondition(ctx, value, constraint)) {
+        return null;
+      }
+      inner = checkAll(ctx, value, constraint.constraints);
+      return inner.length > 0 ? compositeFailure(constraint, inner) : null;
+    }
+    var validator = validators[constraint.type];
+    if (!validator) {
+      return null;
+    }
+    var pass = validator(value, constraint.criteria || {});
+    if (pass === false) {
+      return leafFailure(constraint);
+    }
+    return null;
+  }
+
+  function matchCondition(ctx, value, constraint) {
+    var values = [value];
+    var name = constraint.criteria && constraint.criteria.field;
+    if (typeof name === "string") {
+      if (ctx.rejected[name]) {
+        return false;
+      }
+      if (Object.prototype.hasOwnProperty.call(ctx.fields, name)) {
+        values = [ctx.fields[name]];
+      } else if (Object.prototype.hasOwnProperty.call(ctx.selections, name)) {
+        values = ctx.selections[name];
+      } else {
+        return false;
+      }
+    }
+    return values.some(function (v) {
+      return v !== "" && checkAll(ctx, v, constraint["if"]).length === 0;
+    });
+  }
+
+  return {
+    Form: Form,
+    Result: Result,
+    FilterFailure: FilterFailure,
+    displayWidth: displayWidth,
+    // addValidator adds a validator for the constraint type. It's called
+    // with the value and the criteria, and returns whether the value passes.
+    addValidator: function (type, validator) {
+      validators[type] = function (value, criteria) {
+        return !!validator(value, criteria);
+      };
+    },
+    // addFilter adds a filter, which is called with the value and the args
+    // and returns the converted value, or throws a FilterFailure.
+    addFilter: function (name, filter) {
+      filters[name] = filter;
+    }
+  };
+});
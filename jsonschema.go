@@ -0,0 +1,358 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"math"
+)
+
+// JSONSchemaExtension is the keyword used to carry the constraints which
+// JSON Schema can't express, such as 'hiragana' or 'expr'. Its value is a
+// list of objects with 'type', 'message' and 'criteria'.
+const JSONSchemaExtension = "x-goformkeeper-constraints"
+
+// constraints which only let ASCII characters through, so that their
+// length in bytes equals the number of characters JSON Schema counts.
+var asciiOnlyConstraints = map[string]bool{
+	"alphabet":            true,
+	"alnum":               true,
+	"ascii":               true,
+	"ascii_without_space": true,
+	"loose_email":         true,
+}
+
+var patternConstraints = map[string]string{
+	"alphabet":            "^[a-zA-Z]+$",
+	"alnum":               "^[0-9a-zA-Z]+$",
+	"ascii":               "^[\\x20-\\x7E]+$",
+	"ascii_without_space": "^[\\x21-\\x7E]+$",
+}
+
+// JSONSchema builds a JSON Schema (draft 2020-12) describing the parameters
+// the form accepts, to be marshaled with encoding/json. Fields become
+// string properties and selections become arrays of strings, unless 'int'
+// or 'number' tells they're numbers.
+//
+// Constraints are mapped to the keywords below. The others, and 'length'
+// on fields which may contain non-ASCII characters (JSON Schema counts
+// characters, not bytes), are listed under JSONSchemaExtension.
+//
+//	required             required
+//	rune_count, length   minLength, maxLength
+//	regex                pattern
+//	included             enum
+//	email, loose_email   format: email
+//	url                  format: uri
+//	int                  type: integer, minimum, maximum, multipleOf
+//	number               type: number, minimum, maximum, multipleOf
+//	any_of, all_of, not  anyOf, allOf, not
+//	count of selection   minItems, maxItems
+//
+// Note that constraints apply to filtered values, so the schema may be
+// stricter than the form for input which filters would fix.
+func (rule *Rule) JSONSchema(formName string) (map[string]interface{}, error) {
+	form, found := rule.Forms[formName]
+	if !found {
+		return nil, fmt.Errorf("Form rule not found '%s'", formName)
+	}
+
+	properties, required, err := rule.formJSONSchemaProperties(formName, form, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      formName,
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
+
+// formJSONSchemaProperties builds the schemas of the fields and the
+// selections of the form, and the list of the required ones. With docs,
+// each schema also gets 'default' and 'description' from the rule.
+func (rule *Rule) formJSONSchemaProperties(formName string, form *Form, docs bool) (map[string]interface{}, []string, error) {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for _, field := range form.Fields {
+		if field.Name == "" {
+			return nil, nil, fmt.Errorf("Field name not found on a rule for '%s'", formName)
+		}
+		schema, err := field.jsonSchema()
+		if err != nil {
+			return nil, nil, err
+		}
+		if docs {
+			if field.Default != "" {
+				schema["default"] = field.Default
+			}
+			if field.Message != "" {
+				schema["description"] = field.Message
+			}
+		}
+		properties[field.Name] = schema
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	for _, selection := range form.Selections {
+		if selection.Name == "" {
+			return nil, nil, fmt.Errorf("Selection name not found on a rule for '%s'", formName)
+		}
+		schema, err := selection.jsonSchema()
+		if err != nil {
+			return nil, nil, err
+		}
+		if docs && selection.Message != "" {
+			schema["description"] = selection.Message
+		}
+		properties[selection.Name] = schema
+		if selection.Count != nil && selection.Count.From > 0 {
+			required = append(required, selection.Name)
+		}
+	}
+
+	return properties, required, nil
+}
+
+func (field *Field) jsonSchema() (map[string]interface{}, error) {
+	schema := map[string]interface{}{"type": "string"}
+	err := addConstraintsToJSONSchema(schema, field.Constraints, isASCIIOnly(field.Constraints))
+	if err != nil {
+		return nil, err
+	}
+	if _, found := schema["minLength"]; !found && field.Required && schema["type"] == "string" {
+		schema["minLength"] = 1
+	}
+	return schema, nil
+}
+
+func (selection *Selection) jsonSchema() (map[string]interface{}, error) {
+	items := map[string]interface{}{"type": "string"}
+	err := addConstraintsToJSONSchema(items, selection.Constraints, isASCIIOnly(selection.Constraints))
+	if err != nil {
+		return nil, err
+	}
+	if _, found := items["minLength"]; !found && items["type"] == "string" {
+		items["minLength"] = 1
+	}
+	schema := map[string]interface{}{
+		"type":  "array",
+		"items": items,
+	}
+	if selection.Count != nil {
+		schema["minItems"] = selection.Count.From
+		schema["maxItems"] = selection.Count.To
+	}
+	return schema, nil
+}
+
+func isASCIIOnly(constraints []*Constraint) bool {
+	for _, constraint := range constraints {
+		if asciiOnlyConstraints[constraint.Type] {
+			return true
+		}
+	}
+	return false
+}
+
+func addConstraintsToJSONSchema(schema map[string]interface{}, constraints []*Constraint, asciiOnly bool) error {
+	for _, constraint := range constraints {
+		keywords, err := constraintJSONSchema(constraint, asciiOnly)
+		if err != nil {
+			return err
+		}
+		if keywords == nil {
+			addJSONSchemaExtension(schema, constraint)
+			continue
+		}
+		mergeJSONSchema(schema, keywords)
+	}
+	return nil
+}
+
+// mergeJSONSchema adds keywords to schema. When schema already has one of
+// them, for example a second 'pattern', it's put under 'allOf' instead.
+// A 'type' from 'int' or 'number' replaces 'string'.
+func mergeJSONSchema(schema, keywords map[string]interface{}) {
+	if t, found := keywords["type"]; found && schema["type"] == "string" {
+		schema["type"] = t
+		rest := make(map[string]interface{}, len(keywords)-1)
+		for key, value := range keywords {
+			if key != "type" {
+				rest[key] = value
+			}
+		}
+		keywords = rest
+	}
+	for key := range keywords {
+		if _, found := schema[key]; found {
+			allOf, _ := schema["allOf"].([]interface{})
+			schema["allOf"] = append(allOf, keywords)
+			return
+		}
+	}
+	for key, value := range keywords {
+		schema[key] = value
+	}
+}
+
+func addJSONSchemaExtension(schema map[string]interface{}, constraint *Constraint) {
+	exts, _ := schema[JSONSchemaExtension].([]interface{})
+	schema[JSONSchemaExtension] = append(exts, constraintExtension(constraint))
+}
+
+// constraintExtension writes the constraint as it is in the rule, with the
+// nested ones of composite types and 'when', for JSONSchemaExtension.
+func constraintExtension(constraint *Constraint) map[string]interface{} {
+	ext := map[string]interface{}{"type": constraint.Type}
+	if constraint.ID != "" {
+		ext["id"] = constraint.ID
+	}
+	if constraint.Message != "" {
+		ext["message"] = constraint.Message
+	}
+	if len(constraint.Criteria) > 0 {
+		ext["criteria"] = clientValue(constraint.Criteria)
+	}
+	if len(constraint.If) > 0 {
+		ext["if"] = constraintExtensions(constraint.If)
+	}
+	if len(constraint.Constraints) > 0 {
+		ext["constraints"] = constraintExtensions(constraint.Constraints)
+	}
+	return ext
+}
+
+func constraintExtensions(constraints []*Constraint) []interface{} {
+	exts := make([]interface{}, len(constraints))
+	for i, c := range constraints {
+		exts[i] = constraintExtension(c)
+	}
+	return exts
+}
+
+// constraintJSONSchema returns the keywords expressing the constraint,
+// or nil if JSON Schema can't express it.
+func constraintJSONSchema(constraint *Constraint, asciiOnly bool) (map[string]interface{}, error) {
+	criteria := &Criteria{constraint.Criteria}
+	switch constraint.Type {
+	case "length":
+		if !asciiOnly {
+			return nil, nil
+		}
+		return lengthJSONSchema(criteria, constraint.Type)
+	case "rune_count":
+		return lengthJSONSchema(criteria, constraint.Type)
+	case "regex":
+		regex, err := criteria.String("regex")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"pattern": regex}, nil
+	case "included":
+		in, err := criteria.StringArray("in")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"enum": in}, nil
+	case "email", "loose_email":
+		return map[string]interface{}{"format": "email"}, nil
+	case "url":
+		return map[string]interface{}{"format": "uri"}, nil
+	case "alphabet", "alnum", "ascii", "ascii_without_space":
+		return map[string]interface{}{"pattern": patternConstraints[constraint.Type]}, nil
+	case "int", "number":
+		return numberJSONSchema(criteria, constraint.Type)
+	case "any_of", "all_of", "not":
+		schemas := make([]interface{}, 0)
+		for _, c := range constraint.Constraints {
+			sub := make(map[string]interface{})
+			err := addConstraintsToJSONSchema(sub, []*Constraint{c}, asciiOnly)
+			if err != nil {
+				return nil, err
+			}
+			if _, found := sub[JSONSchemaExtension]; found {
+				return nil, nil
+			}
+			// the type of a branch would contradict the one of the value
+			if _, found := sub["type"]; found {
+				return nil, nil
+			}
+			schemas = append(schemas, sub)
+		}
+		switch constraint.Type {
+		case "any_of":
+			return map[string]interface{}{"anyOf": schemas}, nil
+		case "all_of":
+			return map[string]interface{}{"allOf": schemas}, nil
+		default:
+			return map[string]interface{}{"not": map[string]interface{}{"allOf": schemas}}, nil
+		}
+	}
+	return nil, nil
+}
+
+// numberJSONSchema expresses 'int' and 'number'. As 'step' counts from
+// 'from' while multipleOf counts from 0, a step which doesn't divide
+// 'from' can't be expressed.
+func numberJSONSchema(criteria *Criteria, constraintType string) (map[string]interface{}, error) {
+	schema := map[string]interface{}{"type": "number"}
+	if constraintType == "int" {
+		schema["type"] = "integer"
+	}
+	from := 0.0
+	if criteria.Has("from") {
+		var err error
+		from, err = criteria.Float("from")
+		if err != nil {
+			return nil, err
+		}
+		schema["minimum"] = criteria.values["from"]
+	}
+	if criteria.Has("to") {
+		if _, err := criteria.Float("to"); err != nil {
+			return nil, err
+		}
+		schema["maximum"] = criteria.values["to"]
+	}
+	if criteria.Has("step") {
+		step, err := criteria.Float("step")
+		if err != nil {
+			return nil, err
+		}
+		if step <= 0 {
+			return nil, fmt.Errorf("Criteria 'step' for '%s' should be positive", constraintType)
+		}
+		q := from / step
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			return nil, nil
+		}
+		schema["multipleOf"] = criteria.values["step"]
+	}
+	return schema, nil
+}
+
+func lengthJSONSchema(criteria *Criteria, constraintType string) (map[string]interface{}, error) {
+	if criteria.Has("eq") {
+		eq, err := criteria.Int("eq")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"minLength": eq, "maxLength": eq}, nil
+	} else if criteria.Has("to") && criteria.Has("from") {
+		to, err := criteria.Int("to")
+		if err != nil {
+			return nil, err
+		}
+		from, err := criteria.Int("from")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"minLength": from, "maxLength": to}, nil
+	}
+	return nil, fmt.Errorf("Criteria for '%s' not enough", constraintType)
+}
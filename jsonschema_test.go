@@ -0,0 +1,182 @@
+package goformkeeper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	path := "./tests/schema.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	schema, err := rule.JSONSchema("signup")
+	if err != nil {
+		t.Errorf("Failed to build schema %s", err.Error())
+		return
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Errorf("Failed to marshal schema %s", err.Error())
+		return
+	}
+
+	var got map[string]interface{}
+	json.Unmarshal(data, &got)
+
+	var want map[string]interface{}
+	json.Unmarshal([]byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "signup",
+  "type": "object",
+  "required": ["email", "username", "hobby"],
+  "properties": {
+    "email": {"type": "string", "format": "email", "minLength": 1},
+    "username": {
+      "type": "string",
+      "pattern": "^[0-9a-zA-Z]+$",
+      "minLength": 3,
+      "maxLength": 10,
+      "allOf": [{"pattern": "^[a-z]"}]
+    },
+    "nickname": {
+      "type": "string",
+      "minLength": 0,
+      "maxLength": 10,
+      "x-goformkeeper-constraints": [
+        {"type": "length", "criteria": {"from": 0, "to": 30}},
+        {"type": "katakana", "message": "Input in katakana"}
+      ]
+    },
+    "contact": {
+      "type": "string",
+      "anyOf": [{"format": "email"}, {"format": "uri"}]
+    },
+    "hobby": {
+      "type": "array",
+      "minItems": 1,
+      "maxItems": 3,
+      "items": {"type": "string", "minLength": 1, "enum": ["music", "sports", "books"]}
+    }
+  }
+}`), &want)
+
+	wantData, _ := json.Marshal(want)
+	gotData, _ := json.Marshal(got)
+	if string(wantData) != string(gotData) {
+		t.Errorf("JSONSchema returns wrong schema:\n got %s\nwant %s", gotData, wantData)
+	}
+
+	if _, err := rule.JSONSchema("unknown"); err == nil {
+		t.Errorf("JSONSchema should fail for unknown form")
+	}
+}
+
+func TestJSONSchemaExtension(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/schema.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	schema, err := rule.JSONSchema("address")
+	if err != nil {
+		t.Errorf("Failed to build schema %s", err.Error())
+		return
+	}
+	data, err := json.Marshal(schema["properties"])
+	if err != nil {
+		t.Errorf("Failed to marshal schema %s", err.Error())
+		return
+	}
+
+	var got map[string]interface{}
+	json.Unmarshal(data, &got)
+
+	// composites with a constraint JSON Schema can't express, and 'when',
+	// are written with all the nested constraints
+	var want map[string]interface{}
+	json.Unmarshal([]byte(`{
+  "country": {"type": "string"},
+  "name": {
+    "type": "string",
+    "x-goformkeeper-constraints": [
+      {
+        "type": "any_of",
+        "message": "Input your name in hiragana or alphabets",
+        "constraints": [{"type": "hiragana"}, {"type": "alphabet"}]
+      }
+    ]
+  },
+  "zip": {
+    "type": "string",
+    "x-goformkeeper-constraints": [
+      {
+        "type": "when",
+        "criteria": {"field": "country"},
+        "if": [{"type": "included", "criteria": {"in": ["JP"]}}],
+        "constraints": [{"type": "regex", "criteria": {"regex": "^[0-9]{3}-[0-9]{4}$"}}]
+      }
+    ]
+  }
+}`), &want)
+
+	wantData, _ := json.Marshal(want)
+	gotData, _ := json.Marshal(got)
+	if string(wantData) != string(gotData) {
+		t.Errorf("JSONSchema returns wrong extension:\n got %s\nwant %s", gotData, wantData)
+	}
+}
+
+func TestJSONSchemaNumbers(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/schema.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	schema, err := rule.JSONSchema("order")
+	if err != nil {
+		t.Errorf("Failed to build schema %s", err.Error())
+		return
+	}
+	data, err := json.Marshal(schema["properties"])
+	if err != nil {
+		t.Errorf("Failed to marshal schema %s", err.Error())
+		return
+	}
+
+	var got map[string]interface{}
+	json.Unmarshal(data, &got)
+
+	// 'step' of seats counts from 1, which multipleOf can't express
+	var want map[string]interface{}
+	json.Unmarshal([]byte(`{
+  "qty": {"type": "integer", "minimum": 1, "maximum": 10},
+  "price": {"type": "number", "minimum": 0, "multipleOf": 0.5},
+  "seats": {
+    "type": "string",
+    "x-goformkeeper-constraints": [
+      {"type": "int", "criteria": {"from": 1, "step": 2}}
+    ]
+  },
+  "ids": {"type": "array", "items": {"type": "integer"}}
+}`), &want)
+
+	wantData, _ := json.Marshal(want)
+	gotData, _ := json.Marshal(got)
+	if string(wantData) != string(gotData) {
+		t.Errorf("JSONSchema returns wrong numbers:\n got %s\nwant %s", gotData, wantData)
+	}
+}
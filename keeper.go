@@ -0,0 +1,199 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Keeper owns a set of validators and filters. Rules loaded through a
+// Keeper look up constraint types and filter names only in it, so that
+// several sets can live in one process. A Keeper is safe for concurrent use.
+//
+// The package level functions such as AddValidator and LoadRuleFromFile
+// work on the default Keeper.
+type Keeper struct {
+	mutex      sync.RWMutex
+	validators map[string]Validator
+	filters    map[string]Filter
+	pathParam  PathParamFunc
+	// 'type' and 'title' of the problem details of the results
+	problemType  string
+	problemTitle string
+}
+
+var defaultKeeper = NewKeeper()
+
+// NewKeeper returns a Keeper which has only the built-in validators and
+// filters. Use DefaultKeeper().Clone() to start from the default Keeper
+// including the ones added to it by AddValidator or AddFilter.
+func NewKeeper() *Keeper {
+	k := &Keeper{
+		validators:  make(map[string]Validator),
+		filters:     make(map[string]Filter),
+		problemType: DefaultProblemType,
+	}
+	setDefaultValidators(k)
+	setDefaultFilters(k)
+	return k
+}
+
+func DefaultKeeper() *Keeper {
+	return defaultKeeper
+}
+
+func (k *Keeper) Clone() *Keeper {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	k2 := &Keeper{
+		validators:   make(map[string]Validator, len(k.validators)),
+		filters:      make(map[string]Filter, len(k.filters)),
+		pathParam:    k.pathParam,
+		problemType:  k.problemType,
+		problemTitle: k.problemTitle,
+	}
+	for name, v := range k.validators {
+		k2.validators[name] = v
+	}
+	for name, f := range k.filters {
+		k2.filters[name] = f
+	}
+	return k2
+}
+
+func (k *Keeper) AddValidator(name string, validator Validator) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.validators[name] = validator
+}
+
+func (k *Keeper) AddFilter(name string, f Filter) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.filters[name] = f
+}
+
+func (k *Keeper) AddFilterFunc(funcName string, f func(string) string) {
+	k.AddFilter(funcName, FilterFunc(f))
+}
+
+// SetPathParamFunc sets the function to get the values of the fields and
+// the selections whose source is 'path'.
+func (k *Keeper) SetPathParamFunc(f PathParamFunc) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.pathParam = f
+}
+
+func (k *Keeper) PathParamFunc() PathParamFunc {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.pathParam
+}
+
+// SetProblemType sets the 'type' and the 'title' of the problem details
+// of the results validated through the keeper. Set problemType to the URI
+// documenting validation errors of your API. When title is empty, the
+// text of the status is used.
+func (k *Keeper) SetProblemType(problemType, title string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.problemType = problemType
+	k.problemTitle = title
+}
+
+// ProblemType returns the 'type' and the 'title' of the problem details.
+func (k *Keeper) ProblemType() (string, string) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.problemType, k.problemTitle
+}
+
+func (k *Keeper) Validator(name string) (Validator, bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	v, found := k.validators[name]
+	return v, found
+}
+
+func (k *Keeper) Filter(name string) (Filter, bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	f, found := k.filters[name]
+	return f, found
+}
+
+func (k *Keeper) LoadRuleFromDir(dirPath string) (*Rule, error) {
+	r := newRule()
+	r.keeper = k
+	err := filepath.Walk(dirPath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				// Merge resolves the references, which may be to a
+				// field of another file, and the conditions are checked
+				// after all the files are merged
+				rule, err := loadRuleFromFile(path)
+				if err != nil {
+					return err
+				}
+				r.Merge(rule)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkConditions(); err != nil {
+		return nil, fmt.Errorf("Failed to compile form-rule %s: %s", dirPath, err.Error())
+	}
+	return r, nil
+}
+
+func (k *Keeper) LoadRuleFromFile(filePath string) (*Rule, error) {
+	r, err := loadRuleFromFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	r.keeper = k
+	r.resolveReferences()
+	if err := r.checkConditions(); err != nil {
+		return nil, fmt.Errorf("Failed to compile form-rule %s: %s", filePath, err.Error())
+	}
+	return r, nil
+}
+
+func (k *Keeper) validate(value string, constraint *Constraint) (bool, error) {
+	validator, found := k.Validator(constraint.Type)
+	if !found {
+		return false, fmt.Errorf("Validator not found: %s", constraint.Type)
+	}
+	criteria := &Criteria{constraint.Criteria}
+	ok, err := validator.Validate(value, criteria)
+	return ok, err
+}
+
+func (k *Keeper) filter(filterRule FilterRule, value string) (string, error) {
+	for _, spec := range filterRule.GetFilters() {
+		f, found := k.Filter(spec.Name)
+		if !found {
+			return "", fmt.Errorf("Unknown filter %s", spec.Name)
+		}
+		filtered, err := f.Filter(value, &Criteria{spec.Args})
+		if err != nil {
+			if failure, ok := err.(*FilterFailure); ok {
+				message := failure.Message
+				if message == "" {
+					message = spec.Message
+				}
+				return "", &FilterFailure{FilterName: spec.Name, Message: message}
+			}
+			return "", err
+		}
+		value = filtered
+	}
+	return value, nil
+}
@@ -0,0 +1,104 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type alwaysFailValidator struct{}
+
+func (v *alwaysFailValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	return false, nil
+}
+
+func TestKeeperIsolation(t *testing.T) {
+	k1 := DefaultKeeper().Clone()
+	k2 := NewKeeper()
+
+	k1.AddValidator("length", &alwaysFailValidator{})
+
+	if _, found := k2.Validator("length"); !found {
+		t.Errorf("NewKeeper should have built-in validators")
+	}
+
+	if v, _ := DefaultKeeper().Validator("length"); v == nil {
+		t.Errorf("default keeper should keep its own validator")
+	} else if _, ok := v.(*LengthValidator); !ok {
+		t.Errorf("validator added to a clone shouldn't affect the default keeper")
+	}
+
+	path := "./tests/rules.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	r1, err := k1.LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+	if r1.Keeper() != k1 {
+		t.Errorf("Rule should be bound to the keeper it was loaded through")
+	}
+
+	r2, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	query := "http://www.example.org/?username=foo&password=bar"
+	req1 := &http.Request{Method: "GET"}
+	req1.URL, _ = url.Parse(query)
+	result1, err := r1.Validate("signin", req1)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if !result1.FailedOnConstraint("password", "length") {
+		t.Errorf("rule bound to k1 should use k1's validator")
+	}
+
+	req2 := &http.Request{Method: "GET"}
+	req2.URL, _ = url.Parse(query)
+	result2, err := r2.Validate("signin", req2)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result2.FailedOnConstraint("password", "length") {
+		t.Errorf("rule bound to default keeper shouldn't use k1's validator")
+	}
+}
+
+func TestKeeperConcurrentUse(t *testing.T) {
+	k := NewKeeper()
+	field := &Field{Name: "name", Filters: []*FilterSpec{{Name: "trim"}}}
+	constraint := &Constraint{Type: "length", Criteria: map[string]interface{}{"from": 0, "to": 10}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			k.AddValidator(fmt.Sprintf("custom%d", i), &alwaysFailValidator{})
+			k.AddFilterFunc(fmt.Sprintf("custom%d", i), TrimUnicodeSpace)
+		}(i)
+		go func() {
+			defer wg.Done()
+			value, err := k.filter(field, " foo ")
+			if err != nil {
+				t.Errorf("filter returns error: %s", err.Error())
+				return
+			}
+			if ok, err := k.validate(value, constraint); !ok || err != nil {
+				t.Errorf("validate should pass")
+			}
+		}()
+	}
+	wg.Wait()
+}
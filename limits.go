@@ -0,0 +1,280 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxMemory is the size of the parts of a multipart body kept in
+// memory, when the form doesn't set 'max_memory'. The rest is stored in
+// temporary files.
+const DefaultMaxMemory = 32 << 20
+
+// Limits caps the size and the shape of the request a form accepts. Zero
+// means no limit.
+//
+// The limits are checked while the request is parsed, so a request over
+// them is rejected without parsing the rest.
+//
+//	limits:
+//	  max_body: 1048576
+//	  max_memory: 1048576
+//	  max_keys: 20
+//	  max_values_per_key: 10
+//	  max_value_bytes: 1000
+type Limits struct {
+	// MaxBody is the size of the body in bytes. The body is read through
+	// http.MaxBytesReader, so a larger body is not read to the end.
+	MaxBody int64 `yaml:"max_body"`
+	// MaxMemory is passed to ParseMultipartForm, DefaultMaxMemory if 0.
+	MaxMemory int64 `yaml:"max_memory"`
+	// MaxKeys is the number of the distinct parameters.
+	MaxKeys int `yaml:"max_keys"`
+	// MaxValuesPerKey is the number of the values of a parameter.
+	MaxValuesPerKey int `yaml:"max_values_per_key"`
+	// MaxValueBytes is the size of a value in bytes.
+	MaxValueBytes int `yaml:"max_value_bytes"`
+}
+
+// LimitError is returned by Validate when the request exceeds a limit of
+// the form. Limit is the name of the limit, such as 'max_keys', and Name
+// is the parameter which exceeded it, if any.
+type LimitError struct {
+	Limit string
+	Name  string
+}
+
+func (e *LimitError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("Parameter '%s' exceeds the limit '%s'", e.Name, e.Limit)
+	}
+	return fmt.Sprintf("Request exceeds the limit '%s'", e.Limit)
+}
+
+// ParseError is returned by Validate when the body of the request can't
+// be parsed, such as a multipart body without the boundary.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Failed to parse request: %s", e.Err.Error())
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseRequest parses the query and the body of the request into
+// req.Form within the limits, unless it's already parsed. The number and
+// the size of the values are counted while the query, an urlencoded body
+// and the parts of a multipart body are read, so that the parse stops at
+// the first one over the limits, and checked again on req.Form at last.
+func (limits *Limits) parseRequest(req *http.Request) error {
+	if req.Form == nil {
+		if limits.MaxBody > 0 && req.Body != nil {
+			req.Body = http.MaxBytesReader(nil, req.Body, limits.MaxBody)
+		}
+		if err := limits.parseForm(req); err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				return limitErr
+			}
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return &LimitError{Limit: "max_body"}
+			}
+			return &ParseError{Err: err}
+		}
+	}
+
+	counter := limits.newValueCounter()
+	names := make([]string, 0, len(req.Form))
+	for name := range req.Form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Form[name] {
+			if err := counter.add(name, len(value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (limits *Limits) countsValues() bool {
+	return limits.MaxKeys > 0 || limits.MaxValuesPerKey > 0 || limits.MaxValueBytes > 0
+}
+
+// parseForm parses the request as ParseMultipartForm does, counting the
+// values on the way when the limits need it.
+func (limits *Limits) parseForm(req *http.Request) error {
+	maxMemory := limits.MaxMemory
+	if maxMemory == 0 {
+		maxMemory = DefaultMaxMemory
+	}
+	if limits.countsValues() {
+		counter := limits.newValueCounter()
+		if req.URL != nil {
+			if err := counter.scanQuery(req.URL.RawQuery); err != nil {
+				return err
+			}
+		}
+		mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		switch {
+		case req.Body == nil || !(req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH"):
+		case mediaType == "application/x-www-form-urlencoded":
+			if err := counter.scanBody(req); err != nil {
+				return err
+			}
+		case mediaType == "multipart/form-data" && params["boundary"] != "":
+			return counter.parseMultipartForm(req, params["boundary"], maxMemory)
+		}
+	}
+	// ParseMultipartForm drops the error of ParseForm when the body isn't
+	// multipart, so parse the form by itself first
+	err := req.ParseForm()
+	if err == nil {
+		err = req.ParseMultipartForm(maxMemory)
+	}
+	if err != nil && err != http.ErrNotMultipart {
+		return err
+	}
+	return nil
+}
+
+// maxFormSize is the size of an urlencoded body net/http reads at most,
+// when the body isn't limited by MaxBody.
+const maxFormSize = 10 << 20
+
+// valueCounter counts the parameters and their values against the limits.
+type valueCounter struct {
+	limits *Limits
+	counts map[string]int
+}
+
+func (limits *Limits) newValueCounter() *valueCounter {
+	return &valueCounter{limits: limits, counts: make(map[string]int)}
+}
+
+// add counts a value of the size for the parameter.
+func (c *valueCounter) add(name string, size int) error {
+	if _, found := c.counts[name]; !found && c.limits.MaxKeys > 0 && len(c.counts) >= c.limits.MaxKeys {
+		return &LimitError{Limit: "max_keys"}
+	}
+	c.counts[name]++
+	if c.limits.MaxValuesPerKey > 0 && c.counts[name] > c.limits.MaxValuesPerKey {
+		return &LimitError{Limit: "max_values_per_key", Name: name}
+	}
+	if c.limits.MaxValueBytes > 0 && size > c.limits.MaxValueBytes {
+		return &LimitError{Limit: "max_value_bytes", Name: name}
+	}
+	return nil
+}
+
+// scanQuery counts the parameters of the query, splitting it as
+// url.ParseQuery does. Malformed ones are left to ParseForm to report.
+func (c *valueCounter) scanQuery(query string) error {
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+		// unescaping makes a value shorter, so only long ones need it
+		size := len(value)
+		if c.limits.MaxValueBytes > 0 && size > c.limits.MaxValueBytes {
+			if unescaped, err := url.QueryUnescape(value); err == nil {
+				size = len(unescaped)
+			}
+		}
+		if err := c.add(name, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanBody counts the parameters of the urlencoded body, and puts the
+// body back for ParseForm.
+func (c *valueCounter) scanBody(req *http.Request) error {
+	data, err := io.ReadAll(io.LimitReader(req.Body, maxFormSize+1))
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return c.scanQuery(string(data))
+}
+
+// parseMultipartForm parses the multipart body with ParseMultipartForm,
+// while the parts it reads are counted on the way. The parts are passed
+// through a pipe as they are, and the first one over the limits stops it.
+func (c *valueCounter) parseMultipartForm(req *http.Request, boundary string, maxMemory int64) error {
+	body := req.Body
+	pr, pw := io.Pipe()
+	req.Body = pr
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(c.copyParts(body, pw, boundary))
+	}()
+	err := req.ParseMultipartForm(maxMemory)
+	// stop copying, if ParseMultipartForm has given up on the body
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	return err
+}
+
+func (c *valueCounter) copyParts(body io.Reader, w io.Writer, boundary string) error {
+	reader := multipart.NewReader(body, boundary)
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return writer.Close()
+		}
+		if err != nil {
+			return err
+		}
+		dst, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		// files are not in req.Form
+		if part.FileName() != "" || part.FormName() == "" {
+			if _, err := io.Copy(dst, part); err != nil {
+				return err
+			}
+			continue
+		}
+		src := io.Reader(part)
+		if c.limits.MaxValueBytes > 0 {
+			src = io.LimitReader(part, int64(c.limits.MaxValueBytes)+1)
+		}
+		n, err := io.Copy(dst, src)
+		if err != nil {
+			return err
+		}
+		if err := c.add(part.FormName(), int(n)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLimits(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/limits.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	post := func(body string) *http.Request {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	result, err := rule.Validate("comment", post("body=hello&tag=a&tag=b"))
+	if err != nil || result.HasFailure() {
+		t.Errorf("request within the limits should pass: %v", err)
+	}
+
+	cases := map[string]string{
+		"max_body":           "body=" + strings.Repeat("a", 100),
+		"max_keys":           "body=a&tag=b&c=d&e=f",
+		"max_values_per_key": "body=a&tag=a&tag=b&tag=c",
+		"max_value_bytes":    "body=" + strings.Repeat("a", 17),
+	}
+	for limit, body := range cases {
+		_, err := rule.Validate("comment", post(body))
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("%s: want LimitError, got %v", limit, err)
+			continue
+		}
+		if limitErr.Limit != limit {
+			t.Errorf("%s: got %s", limit, limitErr.Limit)
+		}
+	}
+
+	// the query counts together with the body
+	req := post("body=a&tag=b")
+	req.URL.RawQuery = "tag=c&tag=d"
+	if _, err := rule.Validate("comment", req); !isLimitError(err, "max_values_per_key") {
+		t.Errorf("query: want max_values_per_key, got %v", err)
+	}
+
+	// multipart bodies are counted part by part, and the parse stops at
+	// the first part over the limits without reading the rest
+	multipartCases := map[string]string{
+		"max_keys":           "body=a&tag=b&c=d&e=f",
+		"max_values_per_key": "body=a&tag=a&tag=b&tag=c",
+		"max_value_bytes":    "body=" + strings.Repeat("a", 17),
+	}
+	for limit, values := range multipartCases {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for _, pair := range strings.Split(values, "&") {
+			name, value, _ := strings.Cut(pair, "=")
+			writer.WriteField(name, value)
+		}
+		// the part after the one over the limits ends it
+		writer.WriteField("next", "")
+		body := io.MultiReader(&buf, iotest.ErrReader(errors.New("read past the limit")))
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		if _, err := rule.Validate("upload", req); !isLimitError(err, limit) {
+			t.Errorf("multipart %s: want LimitError, got %v", limit, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("body", "hello")
+	file, _ := writer.CreateFormFile("attachment", "a.txt")
+	file.Write([]byte(strings.Repeat("a", 32)))
+	writer.Close()
+	req = httptest.NewRequest("POST", "/", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	result, err = rule.Validate("upload", req)
+	if err != nil || result.ValidParam("body") != "hello" {
+		t.Errorf("multipart within the limits should pass: %v", err)
+	} else if req.MultipartForm == nil || len(req.MultipartForm.File["attachment"]) != 1 {
+		t.Errorf("files should be parsed as ParseMultipartForm does")
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("--x\r\n"))
+	req.Header.Set("Content-Type", "multipart/form-data")
+	_, err = rule.Validate("comment", req)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("multipart without boundary: want ParseError, got %v", err)
+	}
+
+	handler := rule.Middleware("comment", nil)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, post(cases["max_keys"]))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Middleware with too many keys: want 400, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, post(cases["max_body"]))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Middleware with too large body: want 413, got %d", rec.Code)
+	}
+}
+
+func isLimitError(err error, limit string) bool {
+	var limitErr *LimitError
+	return errors.As(err, &limitErr) && limitErr.Limit == limit
+}
@@ -0,0 +1,132 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+type resultContextKey struct{}
+
+// ContextWithResult returns a copy of ctx which carries the result.
+func ContextWithResult(ctx context.Context, result *Result) context.Context {
+	return context.WithValue(ctx, resultContextKey{}, result)
+}
+
+// ResultFromContext returns the result stored by Middleware, or nil when
+// the request didn't go through it.
+func ResultFromContext(ctx context.Context) *Result {
+	result, _ := ctx.Value(resultContextKey{}).(*Result)
+	return result
+}
+
+// Middleware validates the request with the form before the handler runs.
+//
+//	mux.Handle("/signup", rule.Middleware("signup", nil)(signupHandler))
+//
+// The result is stored in the context of the request, and the handler gets
+// it with ResultFromContext. When the input has failures, onFailure is
+// called instead of the handler, with the result in the context as well.
+// When onFailure is nil, JSONFailureHandler is used.
+//
+// A request whose body exceeds 'max_body' of the form is answered with 413
+// Request Entity Too Large. One exceeding the other limits, or with a body
+// which can't be parsed, is answered with 400 Bad Request.
+// Other errors from Validate, such as an unknown form, are problems of the
+// program, and they're answered with 500 Internal Server Error.
+func (rule *Rule) Middleware(formName string, onFailure http.Handler) func(http.Handler) http.Handler {
+	if onFailure == nil {
+		onFailure = JSONFailureHandler()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			result, err := rule.Validate(formName, req)
+			if err != nil {
+				status := validateErrorStatus(err)
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			req = req.WithContext(ContextWithResult(req.Context(), result))
+			if result.HasFailure() {
+				onFailure.ServeHTTP(w, req)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func validateErrorStatus(err error) int {
+	var limitErr *LimitError
+	var parseErr *ParseError
+	switch {
+	case errors.As(err, &limitErr):
+		if limitErr.Limit == "max_body" {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusBadRequest
+	case errors.As(err, &parseErr):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// JSONFailureHandler answers 422 Unprocessable Entity with the problem
+// details of the result in the context, for APIs. See Result.WriteProblem.
+func JSONFailureHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		result := ResultFromContext(req.Context())
+		if result == nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		result.WriteProblem(w, http.StatusUnprocessableEntity)
+	})
+}
+
+// FailureData is what HTMLFailureHandler passes to the template.
+type FailureData struct {
+	// Result is the result of the validation.
+	Result *Result
+	// Form holds the submitted values, to fill the inputs again. Values of
+	// sensitive fields are left out.
+	Form url.Values
+}
+
+// HTMLFailureHandler renders the form again with tpl, answering 422
+// Unprocessable Entity. The template is executed with *FailureData.
+//
+//	{{ if .Result.FailedOn "email" }}
+//	<p>{{ .Result.MessageOn "email" }}</p>
+//	{{ end }}
+//	<input name="email" value="{{ .Result.Value "email" }}">
+//
+// The output is written only when the template succeeds, otherwise the
+// answer is 500 Internal Server Error.
+func HTMLFailureHandler(tpl *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		result := ResultFromContext(req.Context())
+		if result == nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		var buf bytes.Buffer
+		form := make(url.Values, len(req.Form))
+		for name, values := range req.Form {
+			if !result.IsSensitive(name) {
+				form[name] = values
+			}
+		}
+		err := tpl.Execute(&buf, &FailureData{Result: result, Form: form})
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(buf.Bytes())
+	})
+}
@@ -0,0 +1,93 @@
+package goformkeeper
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	path := "./tests/html.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	var got *Result
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = ResultFromContext(req.Context())
+		w.Write([]byte("ok"))
+	})
+
+	// valid input reaches the handler
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/?email=foo@example.org&username=foo&hobby=music", nil)
+	rule.Middleware("signup", nil)(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("valid input should reach the handler, got %d %s", rec.Code, rec.Body.String())
+	}
+	if got == nil || got.ValidParam("email") != "foo@example.org" {
+		t.Errorf("handler should get the result from the context")
+	}
+
+	// the default failure handler answers JSON
+	got = nil
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/?email=foo&username=foo&hobby=music", nil)
+	rule.Middleware("signup", nil)(handler).ServeHTTP(rec, req)
+	if got != nil {
+		t.Errorf("handler shouldn't run on failure")
+	}
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status: want 422, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != MediaTypeProblemJSON {
+		t.Errorf("Content-Type: want %s, got %s", MediaTypeProblemJSON, ct)
+	}
+	var body struct {
+		InvalidParams []*InvalidParam `json:"invalid-params"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body.InvalidParams) != 1 || body.InvalidParams[0].Name != "email" {
+		t.Errorf("failure of email not found in %s", rec.Body.String())
+	}
+
+	// a custom failure handler gets the result too
+	rec = httptest.NewRecorder()
+	onFailure := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		result := ResultFromContext(req.Context())
+		w.Write([]byte(strings.Join(result.FailedFields(), ",")))
+	})
+	rule.Middleware("signup", onFailure)(handler).ServeHTTP(rec, req)
+	if rec.Body.String() != "email" {
+		t.Errorf("custom failure handler: want email, got %s", rec.Body.String())
+	}
+
+	// re-rendering with html/template
+	tpl := template.Must(template.New("form").Parse(`{{ .Result.MessageOnConstraint "email" "required" }}<input name="email" value="{{ .Form.Get "email" }}">`))
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/?email=&username=<b>&hobby=music", nil)
+	rule.Middleware("signup", HTMLFailureHandler(tpl))(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status: want 422, got %d", rec.Code)
+	}
+	if want := `<input name="email" value="">`; rec.Body.String() != want {
+		t.Errorf("HTMLFailureHandler: want %s, got %s", want, rec.Body.String())
+	}
+
+	// errors of the program are answered with 500
+	rec = httptest.NewRecorder()
+	rule.Middleware("unknown", nil)(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status for unknown form: want 500, got %d", rec.Code)
+	}
+}
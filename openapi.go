@@ -0,0 +1,156 @@
+package goformkeeper
+
+import (
+	"fmt"
+)
+
+const (
+	MediaTypeFormURLEncoded    = "application/x-www-form-urlencoded"
+	MediaTypeMultipartFormData = "multipart/form-data"
+)
+
+// OpenAPIRequestBody builds an OpenAPI 3 Request Body Object for the form,
+// to be marshaled with encoding/json or a YAML encoder. mediaType is either
+// MediaTypeFormURLEncoded or MediaTypeMultipartFormData.
+//
+// The schema is the one JSONSchema builds, with 'default' taken from the
+// default of each field and 'description' from its message. Selections
+// are arrays, sent as repeated parameters. Fields and selections whose
+// source is other than 'body' are left out; see OpenAPIBodyParameters.
+func (rule *Rule) OpenAPIRequestBody(formName, mediaType string) (map[string]interface{}, error) {
+	if mediaType != MediaTypeFormURLEncoded && mediaType != MediaTypeMultipartFormData {
+		return nil, fmt.Errorf("Unsupported media type for a form '%s'", mediaType)
+	}
+	form, found := rule.Forms[formName]
+	if !found {
+		return nil, fmt.Errorf("Form rule not found '%s'", formName)
+	}
+	properties, required, err := rule.formJSONSchemaProperties(formName, form, true)
+	if err != nil {
+		return nil, err
+	}
+	for name, source := range form.sources() {
+		if source != "" && source != SourceBody {
+			delete(properties, name)
+		}
+	}
+	inBody := make([]string, 0, len(required))
+	for _, name := range required {
+		if _, found := properties[name]; found {
+			inBody = append(inBody, name)
+		}
+	}
+	required = inBody
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	// OpenAPI 3.0 doesn't allow an empty list of required properties
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	content := map[string]interface{}{"schema": schema}
+	if mediaType == MediaTypeFormURLEncoded && len(form.Selections) > 0 {
+		encoding := make(map[string]interface{})
+		for _, selection := range form.Selections {
+			if _, found := properties[selection.Name]; !found {
+				continue
+			}
+			encoding[selection.Name] = map[string]interface{}{
+				"style":   "form",
+				"explode": true,
+			}
+		}
+		content["encoding"] = encoding
+	}
+
+	return map[string]interface{}{
+		"required": len(required) > 0,
+		"content": map[string]interface{}{
+			mediaType: content,
+		},
+	}, nil
+}
+
+// OpenAPIParameters builds the list of OpenAPI 3 Parameter Objects for
+// a form submitted as a query string, such as a search form sent by GET.
+// Fields and selections are put 'in' by their source: 'query' when it's
+// empty, and the ones whose source is 'body' are left out.
+func (rule *Rule) OpenAPIParameters(formName string) ([]interface{}, error) {
+	return rule.openAPIParameters(formName, false)
+}
+
+// OpenAPIBodyParameters builds the Parameter Objects for a form submitted
+// in the request body, to go with OpenAPIRequestBody: the fields and the
+// selections whose source is 'query', 'header', 'cookie' or 'path'.
+func (rule *Rule) OpenAPIBodyParameters(formName string) ([]interface{}, error) {
+	return rule.openAPIParameters(formName, true)
+}
+
+func (rule *Rule) openAPIParameters(formName string, inBody bool) ([]interface{}, error) {
+	form, found := rule.Forms[formName]
+	if !found {
+		return nil, fmt.Errorf("Form rule not found '%s'", formName)
+	}
+	properties, required, err := rule.formJSONSchemaProperties(formName, form, true)
+	if err != nil {
+		return nil, err
+	}
+	isRequired := make(map[string]bool, len(required))
+	for _, name := range required {
+		isRequired[name] = true
+	}
+
+	names := make([]string, 0, len(form.Fields)+len(form.Selections))
+	for _, field := range form.Fields {
+		names = append(names, field.Name)
+	}
+	for _, selection := range form.Selections {
+		names = append(names, selection.Name)
+	}
+	sources := form.sources()
+
+	parameters := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		in := openAPIIn(sources[name], inBody)
+		if in == "" {
+			continue
+		}
+		schema := properties[name].(map[string]interface{})
+		parameter := map[string]interface{}{
+			"name":     name,
+			"in":       in,
+			"required": isRequired[name] || in == SourcePath,
+			"schema":   schema,
+		}
+		if description, found := schema["description"]; found {
+			parameter["description"] = description
+			delete(schema, "description")
+		}
+		if schema["type"] == "array" {
+			parameter["style"] = "form"
+			parameter["explode"] = true
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters, nil
+}
+
+// openAPIIn returns the location of the parameter for the source, or ""
+// for the body. The default source is the body when inBody is true.
+func openAPIIn(source string, inBody bool) string {
+	switch source {
+	case "":
+		if inBody {
+			return ""
+		}
+		return "query"
+	case SourceQuery:
+		return "query"
+	case SourceBody:
+		return ""
+	}
+	return source
+}
@@ -0,0 +1,71 @@
+package goformkeeper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenAPIRequestBody(t *testing.T) {
+	path := "./tests/schema.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	body, err := rule.OpenAPIRequestBody("signup", MediaTypeFormURLEncoded)
+	if err != nil {
+		t.Errorf("Failed to build request body %s", err.Error())
+		return
+	}
+
+	content := body["content"].(map[string]interface{})[MediaTypeFormURLEncoded].(map[string]interface{})
+	schema := content["schema"].(map[string]interface{})
+	properties := schema["properties"].(map[string]interface{})
+
+	email := properties["email"].(map[string]interface{})
+	if email["description"] != "Input email address" {
+		t.Errorf("message should be the description: %v", email["description"])
+	}
+
+	nickname := properties["nickname"].(map[string]interface{})
+	if nickname["default"] != "anonymous" {
+		t.Errorf("default should be carried: %v", nickname["default"])
+	}
+
+	hobby := properties["hobby"].(map[string]interface{})
+	if hobby["type"] != "array" {
+		t.Errorf("selection should be an array: %v", hobby["type"])
+	}
+
+	encoding := content["encoding"].(map[string]interface{})
+	if _, found := encoding["hobby"]; !found {
+		t.Errorf("selection should have encoding")
+	}
+
+	if _, err := rule.OpenAPIRequestBody("signup", "application/json"); err == nil {
+		t.Errorf("OpenAPIRequestBody should fail for non-form media type")
+	}
+
+	parameters, err := rule.OpenAPIParameters("signup")
+	if err != nil {
+		t.Errorf("Failed to build parameters %s", err.Error())
+		return
+	}
+	if len(parameters) != 5 {
+		t.Errorf("OpenAPIParameters returns invalid number")
+		return
+	}
+	first := parameters[0].(map[string]interface{})
+	if first["name"] != "email" || first["in"] != "query" || first["required"] != true || first["description"] != "Input email address" {
+		t.Errorf("OpenAPIParameters returns wrong parameter: %v", first)
+	}
+	last := parameters[4].(map[string]interface{})
+	if last["name"] != "hobby" || last["explode"] != true {
+		t.Errorf("OpenAPIParameters returns wrong parameter: %v", last)
+	}
+}
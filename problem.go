@@ -0,0 +1,153 @@
+package goformkeeper
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MediaTypeProblemJSON is the media type of the problem details (RFC 7807).
+const MediaTypeProblemJSON = "application/problem+json"
+
+// DefaultProblemType is the 'type' of the problem details, unless it's set
+// with SetProblemType.
+const DefaultProblemType = "about:blank"
+
+// SetProblemType sets the 'type' and the 'title' of the problem details of
+// the default Keeper.
+func SetProblemType(problemType, title string) {
+	defaultKeeper.SetProblemType(problemType, title)
+}
+
+// InvalidParam is an entry of 'invalid-params' in the problem details.
+type InvalidParam struct {
+	Name        string               `json:"name"`
+	Reason      string               `json:"reason"`
+	Constraints []*InvalidConstraint `json:"constraints"`
+	Values      []string             `json:"values,omitempty"`
+	Items       []*InvalidItem       `json:"items,omitempty"`
+}
+
+// InvalidConstraint is a constraint the parameter failed. Message is the
+// message of the constraint, or the one of the field when it has none.
+type InvalidConstraint struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// InvalidItem tells which value of a selection failed. Value is given only
+// with IncludeRejectedValues.
+type InvalidItem struct {
+	Index       int                  `json:"index"`
+	Value       string               `json:"value,omitempty"`
+	Constraints []*InvalidConstraint `json:"constraints"`
+}
+
+type problemDetails struct {
+	Type          string          `json:"type"`
+	Title         string          `json:"title"`
+	Status        int             `json:"status"`
+	InvalidParams []*InvalidParam `json:"invalid-params"`
+}
+
+// InvalidParams returns the failures in the form of 'invalid-params',
+// sorted by the names of the fields.
+func (result *Result) InvalidParams() []*InvalidParam {
+	params := make([]*InvalidParam, 0, len(result.Failures))
+	for _, name := range result.FailedFields() {
+		param := &InvalidParam{
+			Name:        name,
+			Reason:      result.MessageOn(name),
+			Constraints: make([]*InvalidConstraint, 0),
+		}
+		failure := result.Failures[name]
+		if len(failure.All) > 0 {
+			param.Constraints = invalidConstraints(failure, failure.All)
+		} else {
+			for _, constraintType := range result.FailedConstraintsOn(name) {
+				param.Constraints = append(param.Constraints, &InvalidConstraint{
+					Type:    constraintType,
+					Message: result.MessageOnConstraint(name, constraintType),
+				})
+			}
+		}
+		for _, item := range failure.Items {
+			invalidItem := &InvalidItem{
+				Index:       item.Index,
+				Constraints: invalidConstraints(failure, item.Constraints),
+			}
+			if result.IncludeRejectedValues {
+				invalidItem.Value = item.Value
+			}
+			param.Items = append(param.Items, invalidItem)
+		}
+		if result.IncludeRejectedValues {
+			param.Values = failure.Values
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func invalidConstraints(failure *Failure, constraints []*ConstraintFailure) []*InvalidConstraint {
+	invalid := make([]*InvalidConstraint, len(constraints))
+	for i, c := range constraints {
+		message := c.Message
+		if message == "" {
+			message = failure.Message
+		}
+		invalid[i] = &InvalidConstraint{Type: c.ConstraintType, ID: c.ID, Message: message}
+	}
+	return invalid
+}
+
+func (result *Result) problem(status int) *problemDetails {
+	problemType := result.problemType
+	if problemType == "" {
+		problemType = DefaultProblemType
+	}
+	title := result.problemTitle
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	return &problemDetails{
+		Type:          problemType,
+		Title:         title,
+		Status:        status,
+		InvalidParams: result.InvalidParams(),
+	}
+}
+
+// MarshalJSON encodes the result as the problem details WriteProblem
+// writes for 422 Unprocessable Entity.
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Unprocessable Entity",
+//	  "status": 422,
+//	  "invalid-params": [
+//	    {
+//	      "name": "email",
+//	      "reason": "Input Email",
+//	      "constraints": [{"type": "loose_email", "message": "Not an email"}]
+//	    }
+//	  ]
+//	}
+//
+// With IncludeRejectedValues, each entry also has the submitted 'values'.
+func (result *Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(result.problem(http.StatusUnprocessableEntity))
+}
+
+// WriteProblem answers the request with the problem details of the result
+// as application/problem+json, in the status given.
+func (result *Result) WriteProblem(w http.ResponseWriter, status int) {
+	data, err := json.Marshal(result.problem(status))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", MediaTypeProblemJSON)
+	w.WriteHeader(status)
+	w.Write(data)
+}
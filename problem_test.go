@@ -0,0 +1,116 @@
+package goformkeeper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	path := "./tests/html.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := httptest.NewRequest("GET", "/?email=foo&username=a&age=200&hobby=music", nil)
+	result, err := rule.Validate("signup", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+
+	rec := httptest.NewRecorder()
+	result.WriteProblem(rec, 400)
+	if rec.Code != 400 {
+		t.Errorf("status: want 400, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != MediaTypeProblemJSON {
+		t.Errorf("Content-Type: want %s, got %s", MediaTypeProblemJSON, ct)
+	}
+
+	var problem map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &problem)
+	if err != nil {
+		t.Errorf("Failed to parse problem: %s", err.Error())
+		return
+	}
+	if problem["type"] != "about:blank" || problem["title"] != "Bad Request" || problem["status"] != 400.0 {
+		t.Errorf("unexpected problem: %v", problem)
+	}
+
+	params := problem["invalid-params"].([]interface{})
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.(map[string]interface{})["name"].(string)
+		if _, found := p.(map[string]interface{})["values"]; found {
+			t.Errorf("values shouldn't be included by default")
+		}
+	}
+	if want := []string{"age", "email", "username"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("invalid-params should be sorted: want %v, got %v", want, names)
+	}
+
+	// MarshalJSON gives the same for 422, with the rejected values
+	result.IncludeRejectedValues = true
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Errorf("Failed to marshal: %s", err.Error())
+		return
+	}
+	var body struct {
+		Status        int
+		InvalidParams []*InvalidParam `json:"invalid-params"`
+	}
+	json.Unmarshal(data, &body)
+	if body.Status != 422 {
+		t.Errorf("status: want 422, got %d", body.Status)
+	}
+	email := body.InvalidParams[1]
+	if !reflect.DeepEqual(email.Values, []string{"foo"}) {
+		t.Errorf("values: want [foo], got %v", email.Values)
+	}
+	if len(email.Constraints) != 1 || email.Constraints[0].Type != "loose_email" {
+		t.Errorf("constraints of email: got %v", email.Constraints)
+	}
+}
+
+func TestProblemType(t *testing.T) {
+	keeper := NewKeeper()
+	keeper.SetProblemType("https://example.org/problems/validation", "Validation Failed")
+
+	dir, _ := os.Getwd()
+	rule, err := keeper.Clone().LoadRuleFromFile(filepath.Join(dir, "./tests/html.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := httptest.NewRequest("GET", "/?email=foo&username=a&age=200&hobby=music", nil)
+	result, err := rule.Validate("signup", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+
+	data, _ := json.Marshal(result)
+	var problem map[string]interface{}
+	json.Unmarshal(data, &problem)
+	if problem["type"] != "https://example.org/problems/validation" || problem["title"] != "Validation Failed" {
+		t.Errorf("problem should have the type of the keeper: %v", problem)
+	}
+
+	// the default keeper isn't affected
+	data, _ = json.Marshal(NewResult())
+	json.Unmarshal(data, &problem)
+	if problem["type"] != DefaultProblemType || problem["title"] != "Unprocessable Entity" {
+		t.Errorf("problem should have the default type: %v", problem)
+	}
+}
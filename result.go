@@ -1,28 +1,178 @@
 package goformkeeper
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
 type Result struct {
 	ValidFields     map[string]string
 	ValidSelections map[string][]string
 	Failures        map[string]*Failure
+	// IncludeRejectedValues makes WriteProblem and MarshalJSON include the
+	// submitted values of the failed fields. Values of sensitive fields are
+	// never included.
+	IncludeRejectedValues bool
+	// RawValues holds the submitted values of the fields and the
+	// selections before filters, to fill the inputs again. For a field,
+	// it's the value picked by the duplicates policy. Sensitive fields
+	// and values rejected for their encoding are left out.
+	RawValues map[string][]string
+	// FilteredValues holds the values after filters, whether they passed
+	// the constraints or not.
+	FilteredValues map[string][]string
+	// names of the sensitive fields
+	sensitive map[string]bool
+	// 'type' and 'title' of the problem details, from the keeper which
+	// validated the result
+	problemType  string
+	problemTitle string
 }
 
+// Redacted is written in place of the values of sensitive fields.
+const Redacted = "[REDACTED]"
+
 func NewResult() *Result {
 	return &Result{
 		ValidFields:     make(map[string]string),
 		ValidSelections: make(map[string][]string),
 		Failures:        make(map[string]*Failure),
+		RawValues:       make(map[string][]string),
+		FilteredValues:  make(map[string][]string),
+		sensitive:       make(map[string]bool),
+	}
+}
+
+func (result *Result) putRawValues(name string, values []string) {
+	if !result.IsSensitive(name) {
+		result.RawValues[name] = append([]string{}, values...)
 	}
 }
 
+func (result *Result) putFilteredValues(name string, values []string) {
+	if !result.IsSensitive(name) {
+		result.FilteredValues[name] = append([]string{}, values...)
+	}
+}
+
+// Value returns the submitted value of the field before filters, to fill
+// the input again, or "" for sensitive fields.
+//
+//	<input name="email" value="{{ .Value "email" }}">
+func (result *Result) Value(name string) string {
+	if values := result.RawValues[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns the submitted values of the selection before filters.
+func (result *Result) Values(name string) []string {
+	if values, found := result.RawValues[name]; found {
+		return values
+	}
+	return []string{}
+}
+
+// Checked tells whether value was submitted for the selection, to check
+// the checkbox or select the option again.
+//
+//	<input type="checkbox" name="hobby" value="music" {{ if .Checked "hobby" "music" }}checked{{ end }}>
+func (result *Result) Checked(name, value string) bool {
+	for _, v := range result.RawValues[name] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSensitive tells whether the field is marked 'sensitive' in the rule.
+func (result *Result) IsSensitive(fieldName string) bool {
+	return result.sensitive[fieldName]
+}
+
+// disclosableFields returns ValidFields without the sensitive ones.
+func (result *Result) disclosableFields() map[string]string {
+	fields := make(map[string]string, len(result.ValidFields))
+	for name, value := range result.ValidFields {
+		if !result.IsSensitive(name) {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// String describes the result with the values of sensitive fields
+// replaced by Redacted, so that it can be logged.
+func (result *Result) String() string {
+	fields := make(map[string]string, len(result.ValidFields))
+	for name, value := range result.ValidFields {
+		if result.IsSensitive(name) {
+			value = Redacted
+		}
+		fields[name] = value
+	}
+	return fmt.Sprintf("Result{ValidFields:%v ValidSelections:%v FailedFields:%v}",
+		fields, result.ValidSelections, result.FailedFields())
+}
+
+// GoString is the same as String, for the %#v verb.
+func (result *Result) GoString() string {
+	return result.String()
+}
+
+// The tags of Failure and ConstraintFailure define the encoding of
+// MarshalResult, so keep them stable.
+
 type Failure struct {
-	FieldName   string
-	Constraints map[string]*ConstraintFailure
-	Message     string
+	FieldName string `json:"field_name" yaml:"field_name"`
+	// Constraints holds the failures by constraint type. When constraints
+	// of the same type failed, it holds the first one; see All for each.
+	Constraints map[string]*ConstraintFailure `json:"constraints" yaml:"constraints"`
+	Message     string                        `json:"message" yaml:"message"`
+	// Values holds the values submitted for the field, as they were
+	// before filters.
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+	// All holds every failed constraint in the order of the rule.
+	All []*ConstraintFailure `json:"all,omitempty" yaml:"all,omitempty"`
+	// Items holds the failures of each value of a selection.
+	Items []*ItemFailure `json:"items,omitempty" yaml:"items,omitempty"`
 }
 
 type ConstraintFailure struct {
-	ConstraintType string
-	Message        string
+	ConstraintType string `json:"type" yaml:"type"`
+	Message        string `json:"message" yaml:"message"`
+	// Index is the position of the constraint in the list it's written
+	// in, or -1 for 'required' and filters.
+	Index int `json:"index" yaml:"index"`
+	// ID is the 'id' given to the constraint in the rule.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Inner holds the failures of the constraints nested in a composite
+	// constraint such as any_of.
+	Inner []*ConstraintFailure `json:"inner,omitempty" yaml:"inner,omitempty"`
+}
+
+// Key identifies the constraint within the field: the ID when it's given,
+// otherwise the index, or the type for 'required' and filters.
+func (c *ConstraintFailure) Key() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	if c.Index >= 0 {
+		return strconv.Itoa(c.Index)
+	}
+	return c.ConstraintType
+}
+
+// ItemFailure tells which value of a selection failed. Index is the
+// position of the value among the submitted ones, and Value is the value
+// after filters.
+type ItemFailure struct {
+	Index       int                  `json:"index" yaml:"index"`
+	Value       string               `json:"value" yaml:"value"`
+	Constraints []*ConstraintFailure `json:"constraints" yaml:"constraints"`
 }
 
 func NewFailureForSelection(selectionName, selectionMessage string) *Failure {
@@ -45,8 +195,32 @@ func (failure *Failure) failOnConstraint(constraintType, constraintMessage strin
 	constraintFailure := &ConstraintFailure{
 		ConstraintType: constraintType,
 		Message:        constraintMessage,
+		Index:          -1,
+	}
+	failure.addConstraintFailure(constraintFailure)
+}
+
+func (failure *Failure) addConstraintFailure(constraintFailure *ConstraintFailure) {
+	for _, c := range failure.All {
+		if c.Key() == constraintFailure.Key() {
+			return
+		}
+	}
+	failure.All = append(failure.All, constraintFailure)
+	if _, found := failure.Constraints[constraintFailure.ConstraintType]; !found {
+		failure.Constraints[constraintFailure.ConstraintType] = constraintFailure
+	}
+}
+
+// ConstraintFailure returns the failure of the constraint identified by
+// key, which is the 'id' given in the rule or the index of the constraint.
+func (failure *Failure) ConstraintFailure(key string) *ConstraintFailure {
+	for _, c := range failure.All {
+		if c.Key() == key {
+			return c
+		}
 	}
-	failure.Constraints[constraintType] = constraintFailure
+	return nil
 }
 
 func (result *Result) AddFailure(failure *Failure) {
@@ -54,16 +228,21 @@ func (result *Result) AddFailure(failure *Failure) {
 }
 
 func (result *Result) putRequiredFailure(fieldName, message string) {
-	cFailures := make(map[string]*ConstraintFailure, 0)
-	cFailures["required"] = &ConstraintFailure{
-		ConstraintType: "required",
-		Message:        message,
-	}
-	result.Failures[fieldName] = &Failure{
-		FieldName:   fieldName,
-		Message:     message,
-		Constraints: cFailures,
-	}
+	failure := NewFailureForField(fieldName, message)
+	failure.failOnConstraint("required", message)
+	result.AddFailure(failure)
+}
+
+func (result *Result) putConstraintFailure(fieldName, message, constraintType string) {
+	failure := NewFailureForField(fieldName, message)
+	failure.failOnConstraint(constraintType, message)
+	result.AddFailure(failure)
+}
+
+func (result *Result) putFilterFailure(fieldName, message string, filterFailure *FilterFailure) {
+	failure := NewFailureForField(fieldName, message)
+	failure.failOnConstraint(filterFailure.FilterName, filterFailure.Message)
+	result.AddFailure(failure)
 }
 
 func (result *Result) ValidParam(name string) string {
@@ -97,6 +276,7 @@ func (result *Result) FailedFields() []string {
 	for fieldName, _ := range result.Failures {
 		fields = append(fields, fieldName)
 	}
+	sort.Strings(fields)
 	return fields
 }
 
@@ -109,9 +289,42 @@ func (result *Result) FailedConstraintsOn(fieldName string) []string {
 	for constraintName, _ := range failure.Constraints {
 		constraints = append(constraints, constraintName)
 	}
+	sort.Strings(constraints)
 	return constraints
 }
 
+// UnexpectedParams returns the names of the parameters a strict form
+// doesn't declare, in sorted order.
+func (result *Result) UnexpectedParams() []string {
+	names := make([]string, 0)
+	for _, name := range result.FailedFields() {
+		if result.FailedOnConstraint(name, "unexpected") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// FailedItems returns the failures of each value of the selection, in the
+// order the values were submitted.
+func (result *Result) FailedItems(selectionName string) []*ItemFailure {
+	failure, found := result.Failures[selectionName]
+	if !found || failure.Items == nil {
+		return []*ItemFailure{}
+	}
+	return failure.Items
+}
+
+// FailedOnItem tells whether the value at index of the selection failed.
+func (result *Result) FailedOnItem(selectionName string, index int) bool {
+	for _, item := range result.FailedItems(selectionName) {
+		if item.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
 func (result *Result) Messages() []string {
 	builder := NewUniqueStringArrayBuilder(0)
 	for fieldName, _ := range result.Failures {
@@ -1,7 +1,17 @@
 package goformkeeper
 
 import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	yaml "gopkg.in/yaml.v1"
 )
 
 func TestResultRequired(t *testing.T) {
@@ -118,3 +128,101 @@ func TestResult2(t *testing.T) {
 		t.Errorf("FailedFields() returns wrong field: %s", errorFields[1])
 	}
 }
+
+func TestSensitiveField(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/sensitive.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := httptest.NewRequest("GET", "/?email=foo%40example.org&password=hunter2secret&card_number=4111x", nil)
+	result, err := rule.Validate("payment", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result.ValidParam("password") != "hunter2secret" {
+		t.Errorf("ValidParam should return the sensitive value")
+	}
+	if !result.IsSensitive("password") || !result.IsSensitive("card_number") || result.IsSensitive("email") {
+		t.Errorf("IsSensitive should follow the rule and the reference")
+	}
+
+	result.IncludeRejectedValues = true
+	problem, _ := json.Marshal(result)
+	stored, _ := MarshalResult(result)
+	marshaled, _ := yaml.Marshal(result)
+	outputs := map[string]string{
+		"String":        result.String(),
+		"%+v":           fmt.Sprintf("%+v", result),
+		"%#v":           fmt.Sprintf("%#v", result),
+		"MarshalJSON":   string(problem),
+		"MarshalResult": string(stored),
+		"yaml.Marshal":  string(marshaled),
+	}
+	for name, output := range outputs {
+		if strings.Contains(output, "hunter2secret") || strings.Contains(output, "4111x") {
+			t.Errorf("%s shouldn't include sensitive values: %s", name, output)
+		}
+	}
+	if !strings.Contains(result.String(), "password:"+Redacted) {
+		t.Errorf("String should show the field as redacted: %s", result.String())
+	}
+	if !strings.Contains(string(stored), "foo@example.org") {
+		t.Errorf("MarshalResult should keep other fields: %s", stored)
+	}
+	if !strings.Contains(string(marshaled), "foo@example.org") {
+		t.Errorf("yaml.Marshal should keep other fields: %s", marshaled)
+	}
+
+	tpl := template.Must(template.New("form").Parse(`[{{ .Form.Get "card_number" }}][{{ .Form.Get "email" }}]`))
+	handler := rule.Middleware("payment", HTMLFailureHandler(tpl))(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/?email=foo%40example.org&password=hunter2secret&card_number=4111x", nil))
+	if body := rec.Body.String(); body != "[][foo@example.org]" {
+		t.Errorf("HTMLFailureHandler shouldn't render sensitive values: %s", body)
+	}
+}
+
+func TestSubmittedValues(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/rerender.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := httptest.NewRequest("GET", "/?email=+foo+&nickname=a&nickname=b&password=secret&hobby=music&hobby=+game+&hobby=sports", nil)
+	result, err := rule.Validate("signup", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if !result.FailedOn("email") || !result.FailedOn("hobby") {
+		t.Errorf("email and hobby should fail")
+	}
+
+	if got := result.Value("email"); got != " foo " {
+		t.Errorf("Value of email: want ' foo ', got %q", got)
+	}
+	if got := result.FilteredValues["email"]; len(got) != 1 || got[0] != "foo" {
+		t.Errorf("FilteredValues of email: got %v", got)
+	}
+	if got := result.Value("nickname"); got != "b" {
+		t.Errorf("Value should follow the duplicates policy: got %q", got)
+	}
+	if got := result.Values("hobby"); len(got) != 3 || got[1] != " game " {
+		t.Errorf("Values of hobby: got %v", got)
+	}
+	if !result.Checked("hobby", "music") || result.Checked("hobby", "game") || result.Checked("email", "foo") {
+		t.Errorf("Checked should match the submitted values")
+	}
+	if result.Value("password") != "" || len(result.Values("password")) != 0 || result.FilteredValues["password"] != nil {
+		t.Errorf("values of sensitive fields shouldn't be kept")
+	}
+	if len(result.Values("unknown")) != 0 {
+		t.Errorf("Values of unknown name should be empty")
+	}
+}
@@ -5,8 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 
 	yaml "gopkg.in/yaml.v1"
 )
@@ -15,11 +14,26 @@ type Rule struct {
 	Fields     map[string]*Field
 	Selections map[string]*Selection
 	Forms      map[string]*Form
+	keeper     *Keeper
 }
 
 type Form struct {
 	Fields     []*Field
 	Selections []*Selection
+	// Strict makes parameters which are neither fields, selections nor
+	// listed in Allow fail with the constraint type 'unexpected'.
+	Strict bool
+	Allow  []string
+	// Message is the message for the unexpected parameters.
+	Message string
+	// Duplicates is the policy of the fields which don't set their own.
+	Duplicates string
+	Limits     *Limits
+	// Encoding is 'strict' to check the values with EncodingStrict.
+	Encoding string
+	// Charset is the charset of the values, such as 'shift_jis' or
+	// 'euc-jp', when the request doesn't declare one. UTF-8 by default.
+	Charset string
 }
 
 type Field struct {
@@ -28,19 +42,39 @@ type Field struct {
 	Required    bool
 	Default     string
 	Message     string
-	Filters     []string
+	Filters     []*FilterSpec
 	Constraints []*Constraint
 	FallThrough bool
+	// Duplicates tells what to do when the field is given more than one
+	// value: 'first' (default), 'last', 'join' or 'reject'.
+	Duplicates string
+	// Source is where the value is taken from: 'query', 'body', 'header',
+	// 'cookie' or 'path'. When it's empty, either the query or the body.
+	Source string
+	// Sensitive keeps the value, such as a password, out of everything
+	// but ValidParam: encodings of the result, its String and the form
+	// HTMLFailureHandler renders.
+	Sensitive bool
 }
 
+// policies of Field.Duplicates
+const (
+	DuplicatesFirst  = "first"
+	DuplicatesLast   = "last"
+	DuplicatesJoin   = "join"
+	DuplicatesReject = "reject"
+)
+
 type Selection struct {
 	Name        string
 	Ref         string
 	Count       *Count
 	Message     string
-	Filters     []string
+	Filters     []*FilterSpec
 	Constraints []*Constraint
 	FallThrough bool
+	// Source is where the values are taken from, as Field.Source.
+	Source string
 }
 
 type Count struct {
@@ -57,28 +91,14 @@ func newRule() *Rule {
 }
 
 func LoadRuleFromDir(dirPath string) (*Rule, error) {
-	r := newRule()
-	err := filepath.Walk(dirPath,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				rule, err := LoadRuleFromFile(path)
-				if err != nil {
-					return err
-				}
-				r.Merge(rule)
-			}
-			return nil
-		})
-	if err != nil {
-		return nil, err
-	}
-	return r, nil
+	return defaultKeeper.LoadRuleFromDir(dirPath)
 }
 
 func LoadRuleFromFile(filePath string) (*Rule, error) {
+	return defaultKeeper.LoadRuleFromFile(filePath)
+}
+
+func loadRuleFromFile(filePath string) (*Rule, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read form-rule %s: %s", filePath, err.Error())
@@ -89,13 +109,128 @@ func LoadRuleFromFile(filePath string) (*Rule, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse form-rule %s: %s", filePath, err.Error())
 	}
+	err = r.compile()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile form-rule %s: %s", filePath, err.Error())
+	}
 	return r, nil
 }
 
-func (field *Field) GetFilterNames() []string {
+func (rule *Rule) compile() error {
+	for _, field := range rule.Fields {
+		if err := compileConstraints(field.Constraints); err != nil {
+			return err
+		}
+		if err := compileFilters(field.Filters); err != nil {
+			return err
+		}
+		if err := checkDuplicatesPolicy(field.Duplicates); err != nil {
+			return err
+		}
+		if err := checkSource(field.Source); err != nil {
+			return err
+		}
+	}
+	for _, selection := range rule.Selections {
+		if err := compileConstraints(selection.Constraints); err != nil {
+			return err
+		}
+		if err := compileFilters(selection.Filters); err != nil {
+			return err
+		}
+		if err := checkSource(selection.Source); err != nil {
+			return err
+		}
+	}
+	for _, form := range rule.Forms {
+		if err := checkDuplicatesPolicy(form.Duplicates); err != nil {
+			return err
+		}
+		if err := checkEncoding(form.Encoding); err != nil {
+			return err
+		}
+		if err := checkCharset(form.Charset); err != nil {
+			return err
+		}
+		for _, field := range form.Fields {
+			if err := compileConstraints(field.Constraints); err != nil {
+				return err
+			}
+			if err := compileFilters(field.Filters); err != nil {
+				return err
+			}
+			if err := checkDuplicatesPolicy(field.Duplicates); err != nil {
+				return err
+			}
+			if err := checkSource(field.Source); err != nil {
+				return err
+			}
+		}
+		for _, selection := range form.Selections {
+			if err := compileConstraints(selection.Constraints); err != nil {
+				return err
+			}
+			if err := compileFilters(selection.Filters); err != nil {
+				return err
+			}
+			if err := checkSource(selection.Source); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkDuplicatesPolicy(policy string) error {
+	switch policy {
+	case "", DuplicatesFirst, DuplicatesLast, DuplicatesJoin, DuplicatesReject:
+		return nil
+	}
+	return fmt.Errorf("Unknown duplicates policy '%s'", policy)
+}
+
+// duplicatesPolicy returns the policy for the field in the form.
+func (field *Field) duplicatesPolicy(form *Form) string {
+	if field.Duplicates != "" {
+		return field.Duplicates
+	}
+	if form.Duplicates != "" {
+		return form.Duplicates
+	}
+	return DuplicatesFirst
+}
+
+// pickValue picks the value to validate from the submitted ones by the
+// policy. It returns false when the policy rejects them.
+func (field *Field) pickValue(form *Form, values []string) (string, bool) {
+	if len(values) == 0 {
+		return "", true
+	}
+	switch field.duplicatesPolicy(form) {
+	case DuplicatesLast:
+		return values[len(values)-1], true
+	case DuplicatesJoin:
+		return strings.Join(values, ","), true
+	case DuplicatesReject:
+		if len(values) > 1 {
+			return "", false
+		}
+	}
+	return values[0], true
+}
+
+func (field *Field) GetFilters() []*FilterSpec {
 	return field.Filters
 }
 
+func (field *Field) GetFilterNames() []string {
+	names := make([]string, len(field.Filters))
+	for i, spec := range field.Filters {
+		names[i] = spec.Name
+	}
+	return names
+}
+
 func (field *Field) mergeReferenceIfNeeded(rule *Rule) {
 	if field.Ref != "" {
 		ref, found := rule.Fields[field.Ref]
@@ -108,16 +243,31 @@ func (field *Field) mergeReferenceIfNeeded(rule *Rule) {
 			}
 			field.Required = ref.Required
 			field.Default = ref.Default
+			if field.Duplicates == "" {
+				field.Duplicates = ref.Duplicates
+			}
+			if field.Source == "" {
+				field.Source = ref.Source
+			}
+			field.Sensitive = field.Sensitive || ref.Sensitive
 			field.Constraints = ref.Constraints
 			field.Filters = ref.Filters
 		}
 	}
 }
 
-func (selection *Selection) GetFilterNames() []string {
+func (selection *Selection) GetFilters() []*FilterSpec {
 	return selection.Filters
 }
 
+func (selection *Selection) GetFilterNames() []string {
+	names := make([]string, len(selection.Filters))
+	for i, spec := range selection.Filters {
+		names[i] = spec.Name
+	}
+	return names
+}
+
 func (selection *Selection) mergeReferenceIfNeeded(rule *Rule) {
 	if selection.Ref != "" {
 		ref, found := rule.Selections[selection.Ref]
@@ -129,13 +279,76 @@ func (selection *Selection) mergeReferenceIfNeeded(rule *Rule) {
 				selection.Message = ref.Message
 			}
 			selection.Count = ref.Count
+			if selection.Source == "" {
+				selection.Source = ref.Source
+			}
 			selection.Constraints = ref.Constraints
 			selection.Filters = ref.Filters
 		}
 	}
 }
 
+// Keeper returns the Keeper the rule was loaded through.
+func (rule *Rule) Keeper() *Keeper {
+	if rule.keeper == nil {
+		return defaultKeeper
+	}
+	return rule.keeper
+}
+
+// resolveReferences fills the fields and the selections of the forms
+// which refer to the ones of the rule with 'ref'. It's done when the rule
+// is loaded or merged, not while validating, as the rule is shared by the
+// requests validated at the same time and mustn't be written then.
+func (rule *Rule) resolveReferences() {
+	for _, form := range rule.Forms {
+		for _, field := range form.Fields {
+			field.mergeReferenceIfNeeded(rule)
+		}
+		for _, selection := range form.Selections {
+			selection.mergeReferenceIfNeeded(rule)
+		}
+	}
+}
+
+// checkConditions checks the fields 'when' constraints look at are in
+// their forms, which can be done only after the references are resolved.
+func (rule *Rule) checkConditions() error {
+	for formName, form := range rule.Forms {
+		names := make(map[string]bool)
+		for _, field := range form.Fields {
+			names[field.Name] = true
+		}
+		for _, selection := range form.Selections {
+			names[selection.Name] = true
+		}
+		for _, field := range form.Fields {
+			if err := checkConditionFields(field.Constraints, names); err != nil {
+				return fmt.Errorf("%s on form '%s'", err.Error(), formName)
+			}
+		}
+		for _, selection := range form.Selections {
+			if err := checkConditionFields(selection.Constraints, names); err != nil {
+				return fmt.Errorf("%s on form '%s'", err.Error(), formName)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge adds the fields, the selections and the forms of r2 to the rule,
+// and resolves the references between them. Merge rules before sharing
+// them between goroutines.
 func (r *Rule) Merge(r2 *Rule) {
+	if r.Fields == nil {
+		r.Fields = make(map[string]*Field)
+	}
+	if r.Selections == nil {
+		r.Selections = make(map[string]*Selection)
+	}
+	if r.Forms == nil {
+		r.Forms = make(map[string]*Form)
+	}
 	for k, v := range r2.Fields {
 		r.Fields[k] = v
 	}
@@ -145,10 +358,12 @@ func (r *Rule) Merge(r2 *Rule) {
 	for k, v := range r2.Forms {
 		r.Forms[k] = v
 	}
+	r.resolveReferences()
 }
 
-const defaultMaxMemory = 32 << 20
-
+// Validate validates the parameters of the request with the form. Besides
+// errors of the rule, it returns *LimitError when the request exceeds the
+// limits of the form, and *ParseError when the body can't be parsed.
 func (rule *Rule) Validate(formName string, req *http.Request) (*Result, error) {
 	form, found := rule.Forms[formName]
 	if !found {
@@ -156,57 +371,207 @@ func (rule *Rule) Validate(formName string, req *http.Request) (*Result, error)
 	}
 
 	// we need to pick multiple form values from r.Form
-	if req.Form == nil {
-		req.ParseMultipartForm(defaultMaxMemory)
+	limits := form.Limits
+	if limits == nil {
+		limits = &Limits{}
 	}
+	if err := limits.parseRequest(req); err != nil {
+		return nil, err
+	}
+
+	charset := form.requestCharset(req)
 
 	result := NewResult()
+	ctx := newValidationContext(rule.Keeper())
+	result.problemType, result.problemTitle = ctx.keeper.ProblemType()
+	filterFailures := make(map[string]*FilterFailure)
+	// constraint types of the fields and the selections which failed
+	// before filters, such as 'duplicate'
+	rejected := make(map[string]string)
+	params := newRequestValues(req, ctx.keeper.PathParamFunc())
+	// values as they were submitted, by the name of the field or the
+	// selection, to be kept in failures
+	rawValues := make(map[string][]string)
+	// positions of the filtered values of selections among the submitted
+	// ones, as empty values are dropped
+	selectionIndexes := make(map[string][]int)
 
+	// filter all the values first, so that constraints can refer to
+	// the values of other fields
 	for _, field := range form.Fields {
-		field.mergeReferenceIfNeeded(rule)
 		if field.Name == "" {
 			return nil, fmt.Errorf("Field name not found on a rule for '%s'", formName)
 		}
-		fv := req.FormValue(field.Name)
-		if fv == "" && field.Default != "" {
-			fv = field.Default
+		if field.Sensitive {
+			result.sensitive[field.Name] = true
 		}
-		value, err := filter(field, fv)
+		values, err := params.values(field.Source, field.Name)
 		if err != nil {
 			return nil, err
 		}
-		err = field.validate(result, value)
+		values, ok := decodeValues(charset, field.Source, values)
+		if !ok {
+			// the bytes can't be shown as they are
+			rawValues[field.Name] = []string{}
+			rejected[field.Name] = "invalid_charset"
+			continue
+		}
+		rawValues[field.Name] = values
+		if violation := form.encodingViolation(values); violation != "" {
+			rejected[field.Name] = violation
+			continue
+		}
+		fv, ok := field.pickValue(form, values)
+		if !ok {
+			result.putRawValues(field.Name, values)
+			rejected[field.Name] = "duplicate"
+			continue
+		}
+		if len(values) > 0 {
+			result.putRawValues(field.Name, []string{fv})
+		} else {
+			result.putRawValues(field.Name, []string{})
+		}
+		if fv == "" && field.Default != "" {
+			fv = field.Default
+		}
+		value, err := ctx.keeper.filter(field, fv)
+		if failure, ok := err.(*FilterFailure); ok {
+			filterFailures[field.Name] = failure
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
+		ctx.fields[field.Name] = value
+		result.putFilteredValues(field.Name, []string{value})
 	}
 
 	for _, selection := range form.Selections {
-		selection.mergeReferenceIfNeeded(rule)
 		if selection.Name == "" {
 			return nil, errors.New("Selection name not found")
 		}
-		values := req.Form[selection.Name]
+		values, err := params.values(selection.Source, selection.Name)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := decodeValues(charset, selection.Source, values)
+		if !ok {
+			// the bytes can't be shown as they are
+			rawValues[selection.Name] = []string{}
+			rejected[selection.Name] = "invalid_charset"
+			continue
+		}
+		rawValues[selection.Name] = values
+		if violation := form.encodingViolation(values); violation != "" {
+			rejected[selection.Name] = violation
+			continue
+		}
+		result.putRawValues(selection.Name, values)
 		filteredValues := make([]string, 0)
-		for _, value := range values {
-			filteredValue, err := filter(selection, value)
+		indexes := make([]int, 0)
+		for i, value := range values {
+			filteredValue, err := ctx.keeper.filter(selection, value)
+			if failure, ok := err.(*FilterFailure); ok {
+				filterFailures[selection.Name] = failure
+				break
+			}
 			if err != nil {
 				return nil, err
 			}
 			if filteredValue != "" {
 				filteredValues = append(filteredValues, filteredValue)
+				indexes = append(indexes, i)
 			}
 		}
-		err := selection.validate(result, filteredValues)
+		ctx.selections[selection.Name] = filteredValues
+		selectionIndexes[selection.Name] = indexes
+		if _, found := filterFailures[selection.Name]; !found {
+			result.putFilteredValues(selection.Name, filteredValues)
+		}
+	}
+
+	// the fields and the selections which failed so far have no value,
+	// so 'when' conditions on them don't hold
+	for name := range filterFailures {
+		ctx.rejected[name] = true
+	}
+	for name := range rejected {
+		ctx.rejected[name] = true
+	}
+
+	for _, field := range form.Fields {
+		if failure, found := filterFailures[field.Name]; found {
+			result.putFilterFailure(field.Name, field.Message, failure)
+			continue
+		}
+		if constraintType, found := rejected[field.Name]; found {
+			result.putConstraintFailure(field.Name, field.Message, constraintType)
+			continue
+		}
+		err := field.validate(ctx, result, ctx.fields[field.Name])
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	for _, selection := range form.Selections {
+		if failure, found := filterFailures[selection.Name]; found {
+			result.putFilterFailure(selection.Name, selection.Message, failure)
+			continue
+		}
+		if constraintType, found := rejected[selection.Name]; found {
+			result.putConstraintFailure(selection.Name, selection.Message, constraintType)
+			continue
+		}
+		err := selection.validate(ctx, result, ctx.selections[selection.Name], selectionIndexes[selection.Name])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if form.Strict {
+		form.rejectUnexpected(result, req.Form)
+	}
+
+	for name, failure := range result.Failures {
+		if result.IsSensitive(name) {
+			continue
+		}
+		values, found := rawValues[name]
+		if !found {
+			values = req.Form[name]
+		}
+		failure.Values = append([]string{}, values...)
+	}
+
 	return result, nil
 }
 
-func (field *Field) validate(result *Result, value string) error {
+// rejectUnexpected adds a failure for each parameter the form doesn't
+// declare, so that the parameters of a valid result can be bound as they
+// are without letting others, such as 'is_admin', in.
+func (form *Form) rejectUnexpected(result *Result, params map[string][]string) {
+	declared := make(map[string]bool)
+	for _, field := range form.Fields {
+		declared[field.Name] = true
+	}
+	for _, selection := range form.Selections {
+		declared[selection.Name] = true
+	}
+	for _, name := range form.Allow {
+		declared[name] = true
+	}
+	for name := range params {
+		if !declared[name] {
+			failure := NewFailureForField(name, form.Message)
+			failure.failOnConstraint("unexpected", form.Message)
+			result.AddFailure(failure)
+		}
+	}
+}
+
+func (field *Field) validate(ctx *validationContext, result *Result, value string) error {
 	if value == "" {
 		if field.Required {
 			result.putRequiredFailure(field.Name, field.Message)
@@ -217,14 +582,15 @@ func (field *Field) validate(result *Result, value string) error {
 	} else {
 		failure := NewFailureForField(field.Name, field.Message)
 		passAll := true
-		for _, constraint := range field.Constraints {
-			pass, err := validate(value, constraint)
+		for i, constraint := range field.Constraints {
+			constraintFailure, err := ctx.check(value, constraint)
 			if err != nil {
 				return err
 			}
-			if !pass {
+			if constraintFailure != nil {
 				passAll = false
-				failure.failOnConstraint(constraint.Type, constraint.Message)
+				constraintFailure.Index = i
+				failure.addConstraintFailure(constraintFailure)
 				if !field.FallThrough {
 					break
 				}
@@ -239,7 +605,7 @@ func (field *Field) validate(result *Result, value string) error {
 	return nil
 }
 
-func (selection *Selection) validate(result *Result, values []string) error {
+func (selection *Selection) validate(ctx *validationContext, result *Result, values []string, indexes []int) error {
 	count := len(values)
 	if count >= selection.Count.From && count <= selection.Count.To {
 		if count == 0 {
@@ -247,21 +613,31 @@ func (selection *Selection) validate(result *Result, values []string) error {
 			return nil
 		}
 		failure := NewFailureForSelection(selection.Name, selection.Message)
-		passAll := true
-		for _, value := range values {
-			for _, constraint := range selection.Constraints {
-				pass, err := validate(value, constraint)
+		for n, value := range values {
+			var item *ItemFailure
+			for i, constraint := range selection.Constraints {
+				constraintFailure, err := ctx.check(value, constraint)
 				if err != nil {
 					return err
 				}
-				if !pass {
-					passAll = false
-					failure.failOnConstraint(constraint.Type, constraint.Message)
+				if constraintFailure == nil {
+					continue
+				}
+				constraintFailure.Index = i
+				if item == nil {
+					item = &ItemFailure{Index: indexes[n], Value: value}
+				}
+				item.Constraints = append(item.Constraints, constraintFailure)
+				failure.addConstraintFailure(constraintFailure)
+				if !selection.FallThrough {
 					break
 				}
 			}
+			if item != nil {
+				failure.Items = append(failure.Items, item)
+			}
 		}
-		if passAll {
+		if len(failure.Items) == 0 {
 			result.ValidSelections[selection.Name] = values
 		} else {
 			result.AddFailure(failure)
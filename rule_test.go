@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/kr/pretty"
@@ -78,3 +80,379 @@ func TestLoadRule(t *testing.T) {
 	}
 
 }
+
+func TestLoadRuleFromDir(t *testing.T) {
+	rule, err := LoadRuleFromDir("./tests/refs")
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	// the form refers to a field in another file, and is validated by
+	// many goroutines at once, which 'go test -race' checks
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &http.Request{Method: "GET"}
+			req.URL, _ = url.Parse("http://www.example.org/?username=+foobar+&password=secret")
+			result, err := rule.Validate("signin", req)
+			if err != nil {
+				t.Errorf("Failed to validate: %s", err.Error())
+				return
+			}
+			if result.ValidParam("username") != "FOOBAR" {
+				t.Errorf("Failed validation: want %s, got %s", "FOOBAR", result.ValidParam("username"))
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if attrs := rule.HTMLAttrs("signin", "username"); attrs == "" {
+				t.Errorf("HTMLAttrs should find the field referred to")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMergeRule(t *testing.T) {
+	fields, err := LoadRuleFromFile("./tests/refs/fields.yml")
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+	forms, err := LoadRuleFromFile("./tests/refs/forms.yml")
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	// the form refers to the field of the rule merged into it
+	forms.Merge(fields)
+
+	req := &http.Request{Method: "GET"}
+	req.URL, _ = url.Parse("http://www.example.org/?username=+foobar+&password=secret")
+	result, err := forms.Validate("signin", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result.ValidParam("username") != "FOOBAR" {
+		t.Errorf("Failed validation: want %s, got %s", "FOOBAR", result.ValidParam("username"))
+	}
+}
+
+type rejectNGWordFilter struct{}
+
+func (f *rejectNGWordFilter) Filter(value string, args *Criteria) (string, error) {
+	if value == "admin" {
+		return "", &FilterFailure{}
+	}
+	return value, nil
+}
+
+func TestFiltersWithArgs(t *testing.T) {
+	AddFilter("reject_ng_word", &rejectNGWordFilter{})
+
+	path := "./tests/filters.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?tel=%EF%BC%90%EF%BC%93-1234-5678&bio=+%E3%81%82%E3%81%84%E3%81%86%E3%81%88%E3%81%8A%E3%81%8B+&nickname=admin")
+	req.URL = url
+
+	result, err := rule.Validate("profile", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+
+	if result.ValidParam("tel") != "0312345678" {
+		t.Errorf("Failed validation: want %s, got %s", "0312345678", result.ValidParam("tel"))
+	}
+
+	if result.ValidParam("bio") != "あいうえお" {
+		t.Errorf("Failed validation: want %s, got %s", "あいうえお", result.ValidParam("bio"))
+	}
+
+	if !result.FailedOnConstraint("nickname", "reject_ng_word") {
+		t.Errorf("nickname should fail on reject_ng_word")
+	}
+
+	if result.MessageOnConstraint("nickname", "reject_ng_word") != "Nickname contains NG word" {
+		t.Errorf("MessageOnConstraint returns invalid value %s", result.MessageOnConstraint("nickname", "reject_ng_word"))
+	}
+
+	_, err = LoadRuleFromFile(filepath.Join(dir, "./tests/broken/replace.yml"))
+	if err == nil {
+		t.Errorf("invalid pattern for 'replace' should be reported on load")
+	}
+}
+
+func TestCompositeConstraints(t *testing.T) {
+	path := "./tests/composite.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?contact=%2B819012345678&username=lyo&country=US&zip=12345")
+	req.URL = url
+
+	result, err := rule.Validate("signup", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result.HasFailure() {
+		t.Errorf("RESULT: %v", pretty.Formatter(result.Failures))
+	}
+
+	req2 := &http.Request{Method: "GET"}
+	url2, _ := url.Parse("http://www.example.org/?contact=foobar&username=admin&country=JP&zip=12345")
+	req2.URL = url2
+
+	result2, err := rule.Validate("signup", req2)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+
+	if !result2.FailedOnConstraint("contact", "any_of") {
+		t.Errorf("contact should fail on any_of")
+	}
+	inner := result2.Failures["contact"].Constraints["any_of"].Inner
+	if len(inner) != 2 || inner[0].ConstraintType != "email" || inner[1].Message != "Not a phone number" {
+		t.Errorf("any_of should report inner failures: %v", pretty.Formatter(inner))
+	}
+
+	if result2.MessageOnConstraint("username", "not") != "The username is reserved" {
+		t.Errorf("MessageOnConstraint returns invalid value %s", result2.MessageOnConstraint("username", "not"))
+	}
+
+	if !result2.FailedOnConstraint("zip", "when") {
+		t.Errorf("zip should fail on when")
+	}
+
+	// the condition doesn't hold when the field it looks at is rejected
+	req3 := &http.Request{Method: "GET"}
+	url3, _ := url.Parse("http://www.example.org/?contact=foobar&username=lyo&country=JP&country=US&zip=1")
+	req3.URL = url3
+
+	result3, err := rule.Validate("signup", req3)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if !result3.FailedOnConstraint("country", "duplicate") {
+		t.Errorf("country should fail on duplicate")
+	}
+	if result3.FailedOn("zip") {
+		t.Errorf("zip shouldn't fail when country is rejected: %v", pretty.Formatter(result3.Failures["zip"]))
+	}
+
+	_, err = LoadRuleFromFile(filepath.Join(dir, "./tests/broken/when.yml"))
+	if err == nil {
+		t.Errorf("unknown field for 'when' should be reported on load")
+	}
+}
+
+func TestExprConstraint(t *testing.T) {
+	dir, _ := os.Getwd()
+
+	_, err := LoadRuleFromFile(filepath.Join(dir, "./tests/broken/expr.yml"))
+	if err == nil {
+		t.Errorf("syntax error in expression should be reported on load")
+	}
+
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/expr.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?qty=3&price=1000")
+	req.URL = url
+
+	result, err := rule.Validate("order", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result.HasFailure() {
+		t.Errorf("RESULT: %v", pretty.Formatter(result.Failures))
+	}
+
+	req2 := &http.Request{Method: "GET"}
+	url2, _ := url.Parse("http://www.example.org/?qty=300&price=1000")
+	req2.URL = url2
+
+	result2, err := rule.Validate("order", req2)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if !result2.FailedOnConstraint("price", "expr") {
+		t.Errorf("price should fail on expr")
+	}
+}
+
+func TestConstraintFailures(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/items.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?password=secret&tag=go&tag=&tag=c%2B%2B%2B%2B%2B%2B&tag=toolong&label=c%2B%2Bc%2B%2B")
+	req.URL = url
+
+	result, err := rule.Validate("tags", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+
+	// both regex constraints are recorded
+	password := result.Failures["password"]
+	keys := make([]string, 0)
+	for _, c := range password.All {
+		keys = append(keys, c.Key())
+	}
+	if want := []string{"has_digit", "has_upper", "2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys of failures: want %v, got %v", want, keys)
+	}
+	if c := password.ConstraintFailure("has_upper"); c == nil || c.Message != "Password should have an upper case letter" {
+		t.Errorf("failure of has_upper not found")
+	}
+	if result.MessageOnConstraint("password", "regex") != "Password should have a digit" {
+		t.Errorf("Constraints should keep the first failure of the type")
+	}
+
+	// each bad value of the selection is recorded with its position
+	items := result.FailedItems("tag")
+	if len(items) != 2 {
+		t.Errorf("FailedItems: want 2 items, got %d", len(items))
+		return
+	}
+	if items[0].Index != 2 || items[0].Value != "c++++++" || len(items[0].Constraints) != 2 {
+		t.Errorf("first item: got %v", pretty.Formatter(items[0]))
+	}
+	if items[1].Index != 3 || len(items[1].Constraints) != 1 || items[1].Constraints[0].ConstraintType != "length" {
+		t.Errorf("second item: got %v", pretty.Formatter(items[1]))
+	}
+	if !result.FailedOnItem("tag", 2) || result.FailedOnItem("tag", 0) {
+		t.Errorf("FailedOnItem should tell the bad values")
+	}
+
+	// without fallthrough, checks of a value stop at the first failure
+	labels := result.FailedItems("label")
+	if len(labels) != 1 || len(labels[0].Constraints) != 1 {
+		t.Errorf("label: got %v", pretty.Formatter(labels))
+	}
+}
+
+func TestStrictForm(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/strict.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?nickname=foo&hobby=music&csrf_token=xxx&_method=PUT&is_admin=1&role=admin")
+	req.URL = url
+
+	result, err := rule.Validate("profile", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if want := []string{"is_admin", "role"}; !reflect.DeepEqual(result.UnexpectedParams(), want) {
+		t.Errorf("UnexpectedParams: want %v, got %v", want, result.UnexpectedParams())
+	}
+	if result.MessageOnConstraint("is_admin", "unexpected") != "Unexpected parameter" {
+		t.Errorf("message of unexpected parameter: got %s", result.MessageOnConstraint("is_admin", "unexpected"))
+	}
+	if result.ValidParam("nickname") != "foo" || len(result.ValidSelection("hobby")) != 1 {
+		t.Errorf("declared parameters should be valid")
+	}
+
+	// without strict, undeclared parameters are ignored
+	req = &http.Request{Method: "GET"}
+	req.URL = url
+	result, err = rule.Validate("loose", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result.HasFailure() {
+		t.Errorf("loose form shouldn't fail: %v", result.FailedFields())
+	}
+}
+
+func TestDuplicatesPolicy(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/duplicates.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := &http.Request{Method: "GET"}
+	url, _ := url.Parse("http://www.example.org/?nickname=foo&nickname=bar&email=a%40example.org&email=b%40example.org&tags=go&tags=js&token=1&token=2&role=user&role=admin")
+	req.URL = url
+
+	result, err := rule.Validate("account", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	params := map[string]string{
+		"nickname": "bar",
+		"email":    "a@example.org",
+		"tags":     "go,js",
+	}
+	for name, want := range params {
+		if got := result.ValidParam(name); got != want {
+			t.Errorf("%s: want %s, got %s", name, want, got)
+		}
+	}
+	if !result.FailedOnConstraint("token", "duplicate") || result.MessageOn("token") != "Token is given twice" {
+		t.Errorf("token should fail on duplicate")
+	}
+	if !result.FailedOnConstraint("role", "duplicate") {
+		t.Errorf("role should take the policy of the reference")
+	}
+
+	// a single value passes with reject
+	req = &http.Request{Method: "GET"}
+	url, _ = url.Parse("http://www.example.org/?token=1&role=user")
+	req.URL = url
+	result, _ = rule.Validate("account", req)
+	if result.HasFailure() {
+		t.Errorf("single values shouldn't fail: %v", result.FailedFields())
+	}
+
+	if err := checkDuplicatesPolicy("random"); err == nil {
+		t.Errorf("unknown policy should be an error")
+	}
+}
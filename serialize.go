@@ -0,0 +1,122 @@
+package goformkeeper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v1"
+)
+
+// ResultVersion is the version of the encoding of MarshalResult. It's
+// increased when the encoding changes in a way older readers can't follow.
+const ResultVersion = 1
+
+// resultDocument is the encoding of a Result for storage.
+//
+//	{
+//	  "version": 1,
+//	  "valid_fields": {"email": "foo@example.org"},
+//	  "valid_selections": {"hobby": ["music"]},
+//	  "raw_values": {"email": [" foo@example.org"], "hobby": ["music"]},
+//	  "filtered_values": {"email": ["foo@example.org"], "hobby": ["music"]},
+//	  "failures": {
+//	    "password": {
+//	      "field_name": "password",
+//	      "constraints": {
+//	        "length": {"type": "length", "message": "Too short"}
+//	      },
+//	      "message": "Input password"
+//	    }
+//	  }
+//	}
+type resultDocument struct {
+	Version         int                 `json:"version" yaml:"version"`
+	ValidFields     map[string]string   `json:"valid_fields" yaml:"valid_fields"`
+	ValidSelections map[string][]string `json:"valid_selections" yaml:"valid_selections"`
+	Failures        map[string]*Failure `json:"failures" yaml:"failures"`
+	RawValues       map[string][]string `json:"raw_values,omitempty" yaml:"raw_values,omitempty"`
+	FilteredValues  map[string][]string `json:"filtered_values,omitempty" yaml:"filtered_values,omitempty"`
+}
+
+func (result *Result) document() *resultDocument {
+	return &resultDocument{
+		Version:         ResultVersion,
+		ValidFields:     result.disclosableFields(),
+		ValidSelections: result.ValidSelections,
+		Failures:        result.Failures,
+		RawValues:       result.RawValues,
+		FilteredValues:  result.FilteredValues,
+	}
+}
+
+// MarshalResult encodes the result as JSON to be stored, for example with
+// a draft submission, and restored later with UnmarshalResult. Keys are
+// written in sorted order, so the same result always gives the same bytes.
+//
+// It differs from Result.MarshalJSON, which gives the problem details for
+// API responses, and holds no valid values. Sensitive fields are left out,
+// so they're not valid in the restored result.
+func MarshalResult(result *Result) ([]byte, error) {
+	return json.Marshal(result.document())
+}
+
+// MarshalResultYAML encodes the result as YAML, in the same structure as
+// MarshalResult.
+func MarshalResultYAML(result *Result) ([]byte, error) {
+	return yaml.Marshal(result.document())
+}
+
+// GetYAML makes yaml.Marshal encode the result in the same way as
+// MarshalResultYAML, so that sensitive values aren't written.
+func (result *Result) GetYAML() (string, interface{}) {
+	return "", result.document()
+}
+
+// UnmarshalResult restores a result encoded by MarshalResult or
+// MarshalResultYAML. It fails when the version is unknown.
+func UnmarshalResult(data []byte) (*Result, error) {
+	var doc resultDocument
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse result: %s", err.Error())
+	}
+	if doc.Version != ResultVersion {
+		return nil, fmt.Errorf("Unsupported result version '%d'", doc.Version)
+	}
+
+	result := NewResult()
+	for name, value := range doc.ValidFields {
+		result.ValidFields[name] = value
+	}
+	for name, values := range doc.ValidSelections {
+		if values == nil {
+			values = []string{}
+		}
+		result.ValidSelections[name] = values
+	}
+	for name, values := range doc.RawValues {
+		result.RawValues[name] = values
+	}
+	for name, values := range doc.FilteredValues {
+		result.FilteredValues[name] = values
+	}
+	for name, failure := range doc.Failures {
+		if failure == nil {
+			return nil, fmt.Errorf("Failure not found for '%s'", name)
+		}
+		if failure.FieldName == "" {
+			failure.FieldName = name
+		}
+		if failure.Constraints == nil {
+			failure.Constraints = make(map[string]*ConstraintFailure)
+		}
+		result.Failures[name] = failure
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package goformkeeper
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResultRoundTrip(t *testing.T) {
+	path := "./tests/composite.yml"
+	dir, _ := os.Getwd()
+	path = filepath.Join(dir, path)
+
+	rule, err := LoadRuleFromFile(path)
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	req := httptest.NewRequest("GET", "/?contact=foo&username=admin&country=JP&zip=1234567", nil)
+	result, err := rule.Validate("signup", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if !result.HasFailure() || len(result.ValidFields) == 0 {
+		t.Errorf("the request should have both failures and valid fields")
+		return
+	}
+
+	marshalers := map[string]func(*Result) ([]byte, error){
+		"json": MarshalResult,
+		"yaml": MarshalResultYAML,
+	}
+	for format, marshal := range marshalers {
+		data, err := marshal(result)
+		if err != nil {
+			t.Errorf("Failed to marshal %s: %s", format, err.Error())
+			continue
+		}
+		again, _ := marshal(result)
+		if string(data) != string(again) {
+			t.Errorf("%s encoding should be stable", format)
+		}
+
+		restored, err := UnmarshalResult(data)
+		if err != nil {
+			t.Errorf("Failed to unmarshal %s: %s", format, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(restored.ValidFields, result.ValidFields) {
+			t.Errorf("%s: ValidFields: want %v, got %v", format, result.ValidFields, restored.ValidFields)
+		}
+		if !reflect.DeepEqual(restored.RawValues, result.RawValues) || !reflect.DeepEqual(restored.FilteredValues, result.FilteredValues) {
+			t.Errorf("%s: submitted values should be kept", format)
+		}
+		if !reflect.DeepEqual(restored.FailedFields(), result.FailedFields()) {
+			t.Errorf("%s: FailedFields: want %v, got %v", format, result.FailedFields(), restored.FailedFields())
+		}
+		for _, name := range result.FailedFields() {
+			for _, constraint := range result.FailedConstraintsOn(name) {
+				if !restored.FailedOnConstraint(name, constraint) {
+					t.Errorf("%s: FailedOnConstraint(%s, %s) should be kept", format, name, constraint)
+				}
+				want := result.MessageOnConstraint(name, constraint)
+				if got := restored.MessageOnConstraint(name, constraint); got != want {
+					t.Errorf("%s: MessageOnConstraint(%s, %s): want %s, got %s", format, name, constraint, want, got)
+				}
+			}
+		}
+		if !reflect.DeepEqual(restored.Failures["contact"].Constraints["any_of"].Inner, result.Failures["contact"].Constraints["any_of"].Inner) {
+			t.Errorf("%s: inner failures should be kept", format)
+		}
+	}
+
+	if _, err := UnmarshalResult([]byte(`{"version": 2}`)); err == nil {
+		t.Errorf("unknown version should be an error")
+	}
+	if _, err := UnmarshalResult([]byte("valid_fields: {}\n")); err == nil {
+		t.Errorf("missing version should be an error")
+	}
+}
@@ -0,0 +1,109 @@
+package goformkeeper
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// sources of the values of Field.Source and Selection.Source. When the
+// source is empty, the values are taken from req.Form, which holds both
+// the query string and the body.
+const (
+	SourceQuery  = "query"
+	SourceBody   = "body"
+	SourceHeader = "header"
+	SourceCookie = "cookie"
+	SourcePath   = "path"
+)
+
+// PathParamFunc returns the value of the path parameter of the request,
+// which the router has found, or "" when there's none. With the ServeMux
+// of Go 1.22 or later, it's:
+//
+//	func(req *http.Request, name string) string {
+//		return req.PathValue(name)
+//	}
+type PathParamFunc func(req *http.Request, name string) string
+
+// SetPathParamFunc sets the function to get path parameters of the
+// default Keeper.
+func SetPathParamFunc(f PathParamFunc) {
+	defaultKeeper.SetPathParamFunc(f)
+}
+
+func checkSource(source string) error {
+	switch source {
+	case "", SourceQuery, SourceBody, SourceHeader, SourceCookie, SourcePath:
+		return nil
+	}
+	return fmt.Errorf("Unknown source '%s'", source)
+}
+
+// isFormSource tells whether the values of the source are sent with the
+// form, in the query string or the body.
+func isFormSource(source string) bool {
+	return source == "" || source == SourceQuery || source == SourceBody
+}
+
+// sources returns the sources of the fields and the selections of the
+// form by their names. References have to be merged beforehand.
+func (form *Form) sources() map[string]string {
+	sources := make(map[string]string, len(form.Fields)+len(form.Selections))
+	for _, field := range form.Fields {
+		sources[field.Name] = field.Source
+	}
+	for _, selection := range form.Selections {
+		sources[selection.Name] = selection.Source
+	}
+	return sources
+}
+
+// requestValues picks the values of parameters from the sources of a
+// request. The query string is parsed once, when it's first needed.
+type requestValues struct {
+	req       *http.Request
+	query     url.Values
+	pathParam PathParamFunc
+}
+
+func newRequestValues(req *http.Request, pathParam PathParamFunc) *requestValues {
+	return &requestValues{req: req, pathParam: pathParam}
+}
+
+func (rv *requestValues) values(source, name string) ([]string, error) {
+	switch source {
+	case "":
+		return rv.req.Form[name], nil
+	case SourceQuery:
+		if rv.query == nil {
+			if rv.req.URL != nil {
+				rv.query = rv.req.URL.Query()
+			} else {
+				rv.query = url.Values{}
+			}
+		}
+		return rv.query[name], nil
+	case SourceBody:
+		return rv.req.PostForm[name], nil
+	case SourceHeader:
+		return rv.req.Header.Values(name), nil
+	case SourceCookie:
+		values := make([]string, 0)
+		for _, cookie := range rv.req.Cookies() {
+			if cookie.Name == name {
+				values = append(values, cookie.Value)
+			}
+		}
+		return values, nil
+	case SourcePath:
+		if rv.pathParam == nil {
+			return nil, fmt.Errorf("PathParamFunc not set for '%s'", name)
+		}
+		if value := rv.pathParam(rv.req, name); value != "" {
+			return []string{value}, nil
+		}
+		return []string{}, nil
+	}
+	return nil, fmt.Errorf("Unknown source '%s'", source)
+}
@@ -0,0 +1,118 @@
+package goformkeeper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValueSources(t *testing.T) {
+	keeper := NewKeeper()
+	keeper.SetPathParamFunc(func(req *http.Request, name string) string {
+		if name == "id" {
+			return strings.TrimPrefix(req.URL.Path, "/items/")
+		}
+		return ""
+	})
+	dir, _ := os.Getwd()
+	rule, err := keeper.LoadRuleFromFile(filepath.Join(dir, "./tests/sources.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+
+	body := strings.NewReader("title=Hello&tag=go&page=2")
+	req := httptest.NewRequest("POST", "/items/10?title=Smuggled&tag=js&page=3", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Request-Id", "abc")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+
+	result, err := rule.Validate("update", req)
+	if err != nil {
+		t.Errorf("Failed to validate: %s", err.Error())
+		return
+	}
+	if result.HasFailure() {
+		t.Errorf("shouldn't fail: %v", result.FailedFields())
+	}
+	params := map[string]string{
+		"id":           "10",
+		"title":        "Hello",
+		"page":         "3",
+		"X-Request-Id": "abc",
+		"session":      "s3cr3t",
+	}
+	for name, want := range params {
+		if got := result.ValidParam(name); got != want {
+			t.Errorf("%s: want %s, got %s", name, want, got)
+		}
+	}
+	if !reflect.DeepEqual(result.ValidSelection("tag"), []string{"go"}) {
+		t.Errorf("tag should be taken from the body: got %v", result.ValidSelection("tag"))
+	}
+
+	// values only in the query string don't count for the body
+	req = httptest.NewRequest("POST", "/items/x?title=Smuggled", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	result, _ = rule.Validate("update", req)
+	for _, name := range []string{"id", "title", "X-Request-Id", "session"} {
+		if !result.FailedOn(name) {
+			t.Errorf("%s should fail", name)
+		}
+	}
+	if result.FailedOnConstraint("title", "required") && len(result.Failures["title"].Values) != 0 {
+		t.Errorf("values of title should be taken from the body: got %v", result.Failures["title"].Values)
+	}
+
+	// path parameters need PathParamFunc
+	rule, _ = NewKeeper().LoadRuleFromFile(filepath.Join(dir, "./tests/sources.yml"))
+	if _, err := rule.Validate("update", httptest.NewRequest("GET", "/items/10", nil)); err == nil {
+		t.Errorf("path without PathParamFunc should be an error")
+	}
+
+	if err := checkSource("env"); err == nil {
+		t.Errorf("unknown source should be an error")
+	}
+}
+
+func TestOpenAPIParametersSource(t *testing.T) {
+	dir, _ := os.Getwd()
+	rule, err := LoadRuleFromFile(filepath.Join(dir, "./tests/sources.yml"))
+	if err != nil {
+		t.Errorf("Failed to load rule %s", err.Error())
+		return
+	}
+	parameters, err := rule.OpenAPIParameters("update")
+	if err != nil {
+		t.Errorf("Failed to build parameters: %s", err.Error())
+		return
+	}
+	in := make(map[string]string)
+	for _, p := range parameters {
+		parameter := p.(map[string]interface{})
+		in[parameter["name"].(string)] = parameter["in"].(string)
+	}
+	want := map[string]string{
+		"id":           "path",
+		"page":         "query",
+		"X-Request-Id": "header",
+		"session":      "cookie",
+	}
+	if !reflect.DeepEqual(in, want) {
+		t.Errorf("in: want %v, got %v", want, in)
+	}
+
+	body, _ := rule.OpenAPIRequestBody("update", MediaTypeFormURLEncoded)
+	schema := body["content"].(map[string]interface{})[MediaTypeFormURLEncoded].(map[string]interface{})["schema"].(map[string]interface{})
+	properties := schema["properties"].(map[string]interface{})
+	if len(properties) != 2 || properties["title"] == nil || properties["tag"] == nil {
+		t.Errorf("request body should have title and tag: got %v", properties)
+	}
+	if !reflect.DeepEqual(schema["required"], []string{"title"}) {
+		t.Errorf("required: want [title], got %v", schema["required"])
+	}
+}
@@ -3,16 +3,27 @@ package goformkeeper
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/mail"
 	"net/url"
 	"regexp"
+	"strconv"
 	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/width"
 )
 
 type Constraint struct {
-	Type     string
-	Message  string
-	Criteria map[string]interface{}
+	// ID names the constraint, to tell its failure from the ones of other
+	// constraints of the same type. See ConstraintFailure.Key.
+	ID          string
+	Type        string
+	Message     string
+	Criteria    map[string]interface{}
+	Constraints []*Constraint
+	If          []*Constraint
+	program     *exprProgram
 }
 
 type Criteria struct {
@@ -77,6 +88,22 @@ func (c *Criteria) Int(key string) (int, error) {
 	}
 }
 
+func (c *Criteria) Float(key string) (float64, error) {
+	value, found := c.values[key]
+	if found {
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		default:
+			return 0, fmt.Errorf("Couldn't cast to float '%s'", key)
+		}
+	} else {
+		return 0, fmt.Errorf("Param not found '%s'", key)
+	}
+}
+
 func (c *Criteria) String(key string) (string, error) {
 	value, found := c.values[key]
 	if found {
@@ -202,6 +229,333 @@ func (v *RuneCountValidator) Validate(value string, criteria *Criteria) (bool, e
 	}
 }
 
+type DisplayWidthValidator struct{}
+
+func (v *DisplayWidthValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	if criteria == nil {
+		return false, errors.New("Criteria for 'display_width' not enough")
+	}
+	ambiguousWide := false
+	if criteria.Has("ambiguous_wide") {
+		b, err := criteria.Bool("ambiguous_wide")
+		if err != nil {
+			return false, err
+		}
+		ambiguousWide = b
+	}
+	if criteria.Has("eq") {
+		eq, err := criteria.Int("eq")
+		if err != nil {
+			return false, err
+		}
+		return DisplayWidth(value, ambiguousWide) == eq, nil
+	} else if criteria.Has("to") && criteria.Has("from") {
+		to, err := criteria.Int("to")
+		if err != nil {
+			return false, err
+		}
+		from, err := criteria.Int("from")
+		if err != nil {
+			return false, err
+		}
+		count := DisplayWidth(value, ambiguousWide)
+		return count >= from && count <= to, nil
+	} else {
+		return false, errors.New("Criteria for 'display_width' not enough")
+	}
+}
+
+// DisplayWidth counts the columns value occupies on a fixed-width display.
+// East Asian Wide and Fullwidth characters count as two columns. Ambiguous
+// characters count as two only when ambiguousWide is true, as they do in
+// most Japanese fonts.
+func DisplayWidth(value string, ambiguousWide bool) int {
+	count := 0
+	for _, r := range value {
+		if isWideRune(r, ambiguousWide) {
+			count += 2
+		} else {
+			count += 1
+		}
+	}
+	return count
+}
+
+func isWideRune(r rune, ambiguousWide bool) bool {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	case width.EastAsianAmbiguous:
+		return ambiguousWide
+	}
+	return false
+}
+
+func allowSpace(criteria *Criteria) (bool, error) {
+	if criteria == nil || !criteria.Has("allow_space") {
+		return false, nil
+	}
+	return criteria.Bool("allow_space")
+}
+
+func isSpaceRune(r rune) bool {
+	return r == ' ' || r == '\u3000'
+}
+
+type HiraganaValidator struct{}
+
+func (v *HiraganaValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	space, err := allowSpace(criteria)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	for _, r := range value {
+		if (r >= '\u3041' && r <= '\u3096') || (r >= '\u309D' && r <= '\u309F') || r == '\u30FC' {
+			continue
+		}
+		if space && isSpaceRune(r) {
+			continue
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+type KatakanaValidator struct{}
+
+func (v *KatakanaValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	space, err := allowSpace(criteria)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	for _, r := range value {
+		// U+30FB (katakana middle dot) and U+30FC (prolonged sound mark)
+		// are part of the block and appear in foreign names.
+		if (r >= '\u30A1' && r <= '\u30FF') || (r >= '\u31F0' && r <= '\u31FF') {
+			continue
+		}
+		if space && isSpaceRune(r) {
+			continue
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// FullWidthValidator accepts values made only of wide characters. East
+// Asian Ambiguous characters such as "※", "…", "○" or Greek letters are
+// full-width in JIS X 0208, so they're accepted unless the criteria
+// 'ambiguous_wide' is false.
+type FullWidthValidator struct{}
+
+func (v *FullWidthValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	ambiguousWide := true
+	if criteria != nil && criteria.Has("ambiguous_wide") {
+		b, err := criteria.Bool("ambiguous_wide")
+		if err != nil {
+			return false, err
+		}
+		ambiguousWide = b
+	}
+	if value == "" {
+		return false, nil
+	}
+	for _, r := range value {
+		if !isWideRune(r, ambiguousWide) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type HalfWidthValidator struct{}
+
+func (v *HalfWidthValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	for _, r := range value {
+		if isWideRune(r, true) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// JISX0208Validator accepts values made only of double-byte characters
+// defined in JIS X 0208. Vendor extensions such as NEC special characters
+// (row 13) and IBM extensions are rejected, as are single-byte characters.
+// Set the criteria 'allow_single_byte' to true to also accept JIS X 0201
+// (ASCII and half-width katakana), which makes it a check for plain Shift_JIS.
+type JISX0208Validator struct{}
+
+func (v *JISX0208Validator) Validate(value string, criteria *Criteria) (bool, error) {
+	singleByte := false
+	if criteria != nil && criteria.Has("allow_single_byte") {
+		b, err := criteria.Bool("allow_single_byte")
+		if err != nil {
+			return false, err
+		}
+		singleByte = b
+	}
+	if value == "" {
+		return false, nil
+	}
+	encoder := japanese.ShiftJIS.NewEncoder()
+	for _, r := range value {
+		encoded, err := encoder.String(string(r))
+		if err != nil {
+			return false, nil
+		}
+		if len(encoded) == 1 {
+			if !singleByte {
+				return false, nil
+			}
+			continue
+		}
+		if !isJISX0208Row(shiftJISRow(encoded[0], encoded[1])) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func shiftJISRow(s1, s2 byte) int {
+	var row int
+	if s1 <= 0x9F {
+		row = int(s1-0x81)*2 + 1
+	} else {
+		row = int(s1-0xC1)*2 + 1
+	}
+	if s2 >= 0x9F {
+		row += 1
+	}
+	return row
+}
+
+func isJISX0208Row(row int) bool {
+	return (row >= 1 && row <= 8) || (row >= 16 && row <= 84)
+}
+
+// IntValidator accepts decimal integers. Optionally the criteria 'from'
+// and 'to' limit the range, and 'step' requires the value to be a multiple
+// of it counted from 'from' (or 0).
+type IntValidator struct{}
+
+// parseInt parses the value as IntValidator does. Result.ValidInt uses it
+// too, so a value which passed 'int' always parses.
+func parseInt(value string) (int64, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (v *IntValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	n, err := parseInt(value)
+	if err != nil {
+		return false, nil
+	}
+	if criteria == nil {
+		return true, nil
+	}
+	base := int64(0)
+	if criteria.Has("from") {
+		from, err := criteria.Int("from")
+		if err != nil {
+			return false, err
+		}
+		if n < int64(from) {
+			return false, nil
+		}
+		base = int64(from)
+	}
+	if criteria.Has("to") {
+		to, err := criteria.Int("to")
+		if err != nil {
+			return false, err
+		}
+		if n > int64(to) {
+			return false, nil
+		}
+	}
+	if criteria.Has("step") {
+		step, err := criteria.Int("step")
+		if err != nil {
+			return false, err
+		}
+		if step <= 0 {
+			return false, errors.New("Criteria 'step' for 'int' should be positive")
+		}
+		if (n-base)%int64(step) != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// NumberValidator accepts decimal numbers such as "1.5" or "-3". The
+// criteria are the same as the ones of IntValidator, and may be floats.
+type NumberValidator struct{}
+
+var numberPattern = regexp.MustCompile("^[-+]?([0-9]+(\\.[0-9]*)?|\\.[0-9]+)([eE][-+]?[0-9]+)?$")
+
+// parseNumber parses the value as NumberValidator does. It doesn't accept
+// the forms strconv.ParseFloat does besides decimals, such as "NaN".
+func parseNumber(value string) (float64, error) {
+	if !numberPattern.MatchString(value) {
+		return 0, fmt.Errorf("Invalid number '%s'", value)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func (v *NumberValidator) Validate(value string, criteria *Criteria) (bool, error) {
+	n, err := parseNumber(value)
+	if err != nil {
+		return false, nil
+	}
+	if criteria == nil {
+		return true, nil
+	}
+	base := 0.0
+	if criteria.Has("from") {
+		from, err := criteria.Float("from")
+		if err != nil {
+			return false, err
+		}
+		if n < from {
+			return false, nil
+		}
+		base = from
+	}
+	if criteria.Has("to") {
+		to, err := criteria.Float("to")
+		if err != nil {
+			return false, err
+		}
+		if n > to {
+			return false, nil
+		}
+	}
+	if criteria.Has("step") {
+		step, err := criteria.Float("step")
+		if err != nil {
+			return false, err
+		}
+		if step <= 0 {
+			return false, errors.New("Criteria 'step' for 'number' should be positive")
+		}
+		q := (n - base) / step
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 type IncludedValidator struct{}
 
 func (v *IncludedValidator) Validate(value string, criteria *Criteria) (bool, error) {
@@ -251,33 +605,28 @@ func (v *LengthValidator) Validate(value string, criteria *Criteria) (bool, erro
 	}
 }
 
-var validators map[string]Validator
-
-func init() {
-	validators = make(map[string]Validator)
-	AddValidator("length", &LengthValidator{})
-	AddValidator("rune_count", &RuneCountValidator{})
-	AddValidator("alphabet", &AlphabetValidator{})
-	AddValidator("alnum", &AlphabetAndNumberValidator{})
-	AddValidator("ascii", &AsciiValidator{})
-	AddValidator("ascii_without_space", &AsciiWithoutSpaceValidator{})
-	AddValidator("regex", &RegExpValidator{})
-	AddValidator("url", &URLValidator{})
-	AddValidator("email", &EmailAddressValidator{})
-	AddValidator("loose_email", &LooseEmailAddressValidator{})
-	AddValidator("included", &IncludedValidator{})
-}
-
 func AddValidator(name string, validator Validator) {
-	validators[name] = validator
+	defaultKeeper.AddValidator(name, validator)
 }
 
-func validate(value string, constraint *Constraint) (bool, error) {
-	validator, found := validators[constraint.Type]
-	if !found {
-		return false, fmt.Errorf("Validator not found: %s", constraint.Type)
-	}
-	criteria := &Criteria{constraint.Criteria}
-	ok, err := validator.Validate(value, criteria)
-	return ok, err
+func setDefaultValidators(k *Keeper) {
+	k.AddValidator("length", &LengthValidator{})
+	k.AddValidator("rune_count", &RuneCountValidator{})
+	k.AddValidator("display_width", &DisplayWidthValidator{})
+	k.AddValidator("alphabet", &AlphabetValidator{})
+	k.AddValidator("alnum", &AlphabetAndNumberValidator{})
+	k.AddValidator("ascii", &AsciiValidator{})
+	k.AddValidator("ascii_without_space", &AsciiWithoutSpaceValidator{})
+	k.AddValidator("hiragana", &HiraganaValidator{})
+	k.AddValidator("katakana", &KatakanaValidator{})
+	k.AddValidator("full_width", &FullWidthValidator{})
+	k.AddValidator("half_width", &HalfWidthValidator{})
+	k.AddValidator("jisx0208", &JISX0208Validator{})
+	k.AddValidator("regex", &RegExpValidator{})
+	k.AddValidator("url", &URLValidator{})
+	k.AddValidator("email", &EmailAddressValidator{})
+	k.AddValidator("loose_email", &LooseEmailAddressValidator{})
+	k.AddValidator("included", &IncludedValidator{})
+	k.AddValidator("int", &IntValidator{})
+	k.AddValidator("number", &NumberValidator{})
 }
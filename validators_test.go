@@ -17,3 +17,153 @@ func TestLooseEmail(t *testing.T) {
 	}
 
 }
+
+func TestJapaneseValidators(t *testing.T) {
+
+	hiragana := HiraganaValidator{}
+
+	if ok, _ := hiragana.Validate("やまだ たろう", &Criteria{map[string]interface{}{"allow_space": true}}); !ok {
+		t.Errorf("hiragana should allow space when allow_space is set")
+	}
+
+	if ok, _ := hiragana.Validate("やまだ たろう", &Criteria{}); ok {
+		t.Errorf("hiragana shouldn't allow space by default")
+	}
+
+	if ok, _ := hiragana.Validate("ヤマダ", &Criteria{}); ok {
+		t.Errorf("hiragana shouldn't allow katakana")
+	}
+
+	katakana := KatakanaValidator{}
+
+	if ok, _ := katakana.Validate("ジョン・スミスー", &Criteria{}); !ok {
+		t.Errorf("katakana should allow middle dot and prolonged sound mark")
+	}
+
+	if ok, _ := katakana.Validate("ﾔﾏﾀﾞ", &Criteria{}); ok {
+		t.Errorf("katakana shouldn't allow half-width katakana")
+	}
+
+	full := FullWidthValidator{}
+
+	if ok, _ := full.Validate("東京都１２３", &Criteria{}); !ok {
+		t.Errorf("full_width should allow kanji and full-width digits")
+	}
+
+	if ok, _ := full.Validate("東京都123", &Criteria{}); ok {
+		t.Errorf("full_width shouldn't allow ascii digits")
+	}
+
+	for _, value := range []string{"東京都※", "１丁目…", "摂氏３０℃", "Ａ→Ｂ", "αβ", "○×"} {
+		if ok, _ := full.Validate(value, &Criteria{}); !ok {
+			t.Errorf("full_width should allow ambiguous characters: %s", value)
+		}
+		if ok, _ := full.Validate(value, &Criteria{map[string]interface{}{"ambiguous_wide": false}}); ok {
+			t.Errorf("full_width shouldn't allow ambiguous characters with ambiguous_wide false: %s", value)
+		}
+	}
+
+	half := HalfWidthValidator{}
+
+	if ok, _ := half.Validate("abcｱｲｳ", &Criteria{}); !ok {
+		t.Errorf("half_width should allow ascii and half-width katakana")
+	}
+
+	if ok, _ := half.Validate("abcア", &Criteria{}); ok {
+		t.Errorf("half_width shouldn't allow full-width katakana")
+	}
+
+	jis := JISX0208Validator{}
+
+	if ok, _ := jis.Validate("漢字ひらがな", &Criteria{}); !ok {
+		t.Errorf("jisx0208 should allow JIS X 0208 characters")
+	}
+
+	if ok, _ := jis.Validate("①", &Criteria{}); ok {
+		t.Errorf("jisx0208 shouldn't allow NEC special characters")
+	}
+
+	if ok, _ := jis.Validate("髙", &Criteria{}); ok {
+		t.Errorf("jisx0208 shouldn't allow IBM extensions")
+	}
+
+	if ok, _ := jis.Validate("𠮷", &Criteria{}); ok {
+		t.Errorf("jisx0208 shouldn't allow characters outside Shift_JIS")
+	}
+
+	if ok, _ := jis.Validate("ABC漢字", &Criteria{}); ok {
+		t.Errorf("jisx0208 shouldn't allow single byte characters by default")
+	}
+
+	if ok, _ := jis.Validate("ABCｱ漢字", &Criteria{map[string]interface{}{"allow_single_byte": true}}); !ok {
+		t.Errorf("jisx0208 should allow single byte characters when allow_single_byte is set")
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+
+	if w := DisplayWidth("abcあいう", false); w != 9 {
+		t.Errorf("DisplayWidth returns wrong width %d", w)
+	}
+
+	if w := DisplayWidth("○", false); w != 1 {
+		t.Errorf("DisplayWidth counts ambiguous character as %d", w)
+	}
+
+	if w := DisplayWidth("○", true); w != 2 {
+		t.Errorf("DisplayWidth counts ambiguous character as %d with ambiguousWide", w)
+	}
+
+	v := DisplayWidthValidator{}
+
+	if ok, _ := v.Validate("あいう", &Criteria{map[string]interface{}{"from": 0, "to": 5}}); ok {
+		t.Errorf("display_width should count wide characters as two columns")
+	}
+
+	if ok, _ := v.Validate("あいう", &Criteria{map[string]interface{}{"eq": 6}}); !ok {
+		t.Errorf("display_width should match eq")
+	}
+}
+
+func TestNumberValidators(t *testing.T) {
+
+	i := IntValidator{}
+
+	if ok, _ := i.Validate("-12", &Criteria{}); !ok {
+		t.Errorf("int should allow negative numbers")
+	}
+
+	if ok, _ := i.Validate("1.5", &Criteria{}); ok {
+		t.Errorf("int shouldn't allow decimals")
+	}
+
+	if ok, _ := i.Validate("15", &Criteria{map[string]interface{}{"from": 5, "to": 20, "step": 5}}); !ok {
+		t.Errorf("int should allow a value on the step")
+	}
+
+	if ok, _ := i.Validate("16", &Criteria{map[string]interface{}{"from": 5, "to": 20, "step": 5}}); ok {
+		t.Errorf("int shouldn't allow a value off the step")
+	}
+
+	if ok, _ := i.Validate("25", &Criteria{map[string]interface{}{"from": 5, "to": 20}}); ok {
+		t.Errorf("int shouldn't allow a value out of range")
+	}
+
+	n := NumberValidator{}
+
+	if ok, _ := n.Validate("0.3", &Criteria{map[string]interface{}{"from": 0, "to": 1.0, "step": 0.1}}); !ok {
+		t.Errorf("number should allow a value on the step")
+	}
+
+	if ok, _ := n.Validate("0.35", &Criteria{map[string]interface{}{"step": 0.1}}); ok {
+		t.Errorf("number shouldn't allow a value off the step")
+	}
+
+	if ok, _ := n.Validate("Inf", &Criteria{}); ok {
+		t.Errorf("number shouldn't allow Inf")
+	}
+
+	if ok, _ := n.Validate("0x10", &Criteria{}); ok {
+		t.Errorf("number shouldn't allow hex")
+	}
+}